@@ -0,0 +1,193 @@
+package cache
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/bukalapak/ottoman/encoding/json"
+	"github.com/pkg/errors"
+)
+
+// Batch is a single origin request serving multiple cache keys.
+type Batch struct {
+	Keys    []string
+	Request *http.Request
+}
+
+// BatchResolver is the interface for resolving a set of normalized cache keys to one or more batched http requests.
+type BatchResolver interface {
+	ResolveBatch(keys []string, r *http.Request) ([]*Batch, error)
+}
+
+// Splitter is the interface for mapping a batched JSON response back to the value of each cache key.
+type Splitter interface {
+	Split(keys []string, n *json.Node) (map[string][]byte, error)
+}
+
+// ObjectSplitter splits JSON object response keyed by item identifier, e.g. {"1":{...},"2":{...}}.
+type ObjectSplitter struct {
+	// Path is the optional key of the object holding the items, e.g. "data".
+	Path string
+
+	// ID maps cache key to item identifier. Default to the key without namespace.
+	ID func(key string) string
+}
+
+// Split implements Splitter.
+func (s *ObjectSplitter) Split(keys []string, n *json.Node) (map[string][]byte, error) {
+	if s.Path != "" {
+		n = n.Get(s.Path)
+	}
+
+	if !n.IsObject() {
+		return nil, errors.New("batch response is not an object")
+	}
+
+	m := make(map[string][]byte, len(keys))
+
+	for _, k := range keys {
+		if v := n.Get(batchID(s.ID, k)); v.IsValid() && !v.IsNull() {
+			m[k] = v.Bytes()
+		}
+	}
+
+	return m, nil
+}
+
+// ArraySplitter splits JSON array response, matching each item by its identifier field, e.g. [{"id":1,...},{"id":2,...}].
+type ArraySplitter struct {
+	// Path is the optional key of the object holding the items, e.g. "data".
+	Path string
+
+	// Field is the item identifier field. Default to "id".
+	Field string
+
+	// ID maps cache key to item identifier. Default to the key without namespace.
+	ID func(key string) string
+}
+
+// Split implements Splitter.
+func (s *ArraySplitter) Split(keys []string, n *json.Node) (map[string][]byte, error) {
+	if s.Path != "" {
+		n = n.Get(s.Path)
+	}
+
+	if !n.IsArray() {
+		return nil, errors.New("batch response is not an array")
+	}
+
+	field := s.Field
+	if field == "" {
+		field = "id"
+	}
+
+	items := make(map[string][]byte, n.Len())
+
+	for i := 0; i < n.Len(); i++ {
+		v := n.GetN(i)
+		items[nodeID(v.Get(field))] = v.Bytes()
+	}
+
+	m := make(map[string][]byte, len(keys))
+
+	for _, k := range keys {
+		if b, ok := items[batchID(s.ID, k)]; ok {
+			m[k] = b
+		}
+	}
+
+	return m, nil
+}
+
+func batchID(fn func(string) string, key string) string {
+	if fn == nil {
+		return Normalize(key, "")
+	}
+
+	return fn(key)
+}
+
+func nodeID(n *json.Node) string {
+	if n.IsString() {
+		return n.String()
+	}
+
+	return string(bytes.TrimSpace(n.Bytes()))
+}
+
+func (n RemoteOption) splitter() Splitter {
+	if n.Splitter == nil {
+		return &ObjectSplitter{}
+	}
+
+	return n.Splitter
+}
+
+func chunkKeys(keys []string, size int) [][]string {
+	if size <= 0 {
+		size = len(keys)
+	}
+
+	var z [][]string
+
+	for i := 0; i < len(keys); i += size {
+		z = append(z, keys[i:min(i+size, len(keys))])
+	}
+
+	return z
+}
+
+func (p *remoteProvider) fetchBatch(keys []string, r *http.Request) (map[string][]byte, map[string]*FetchInfo, error) {
+	ks := p.NormalizeMulti(keys)
+	c := newCollector()
+
+	var bs []*Batch
+
+	for _, chunk := range chunkKeys(ks, p.option.MaxBatchSize) {
+		z, err := p.option.BatchResolver.ResolveBatch(chunk, r)
+		if err != nil {
+			c.fail(chunk, err)
+			continue
+		}
+
+		bs = append(bs, z...)
+	}
+
+	failed := p.run(r, len(bs), func(i int) {
+		p.fetchBatchRequest(bs[i], r, c)
+	})
+
+	for _, i := range failed {
+		c.fail(bs[i].Keys, r.Context().Err())
+	}
+
+	return c.result()
+}
+
+func (p *remoteProvider) fetchBatchRequest(x *Batch, r *http.Request, c *collector) {
+	b, n, err := p.fetchRequest(withContext(x.Request, r))
+	if err != nil {
+		c.fail(x.Keys, err)
+		return
+	}
+
+	node := json.NewNode(bytes.NewReader(b))
+	if err := node.Error(); err != nil {
+		c.fail(x.Keys, err)
+		return
+	}
+
+	m, err := p.option.splitter().Split(x.Keys, node)
+	if err != nil {
+		c.fail(x.Keys, err)
+		return
+	}
+
+	for _, k := range x.Keys {
+		if v, ok := m[k]; ok {
+			c.add(k, v, n, nil)
+		} else {
+			c.add(k, nil, nil, errors.New("missing from batch response"))
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package cache_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/bukalapak/ottoman/cache"
+	"github.com/bukalapak/ottoman/encoding/json"
+	httpclone "github.com/bukalapak/ottoman/http/clone"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemoteProvider_FetchMultiBatch(t *testing.T) {
+	var hits int32
+
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+
+		switch r.URL.Path {
+		case "/items":
+			ids := strings.Split(r.URL.Query().Get("ids"), ",")
+			items := make([]string, 0, len(ids))
+
+			for _, id := range ids {
+				if id != "404" {
+					items = append(items, `{"id":`+id+`,"name":"item-`+id+`"}`)
+				}
+			}
+
+			io.WriteString(w, `{"data":[`+strings.Join(items, ",")+`]}`)
+		case "/bad":
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}
+
+	h1 := httptest.NewServer(http.HandlerFunc(fn))
+	defer h1.Close()
+
+	c1 := cache.NewProvider(newSample(), "zzz")
+
+	t.Run("FetchMulti", func(t *testing.T) {
+		atomic.StoreInt32(&hits, 0)
+
+		q1 := cache.NewRemoteProvider(c1, cache.RemoteOption{
+			BatchResolver: &batchResolver{path: "/items"},
+			Splitter:      &cache.ArraySplitter{Path: "data"},
+			MaxBatchSize:  2,
+		})
+
+		r, _ := http.NewRequest("GET", h1.URL, nil)
+		mb, mn, err := q1.FetchMulti([]string{"1", "2", "zzz:3", "404"}, r)
+
+		assert.Contains(t, err.Error(), "zzz:404: missing from batch response")
+		assert.Len(t, mb, 3)
+		assert.Equal(t, []byte(`{"id":1,"name":"item-1"}`), mb["zzz:1"])
+		assert.Equal(t, []byte(`{"id":3,"name":"item-3"}`), mb["zzz:3"])
+		assert.Equal(t, http.StatusOK, mn["zzz:2"].StatusCode)
+		assert.Contains(t, mn["zzz:3"].RemoteURL, "ids=3,404")
+		assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+	})
+
+	t.Run("FetchMulti (backend failure)", func(t *testing.T) {
+		q1 := cache.NewRemoteProvider(c1, cache.RemoteOption{
+			BatchResolver: &batchResolver{path: "/bad"},
+		})
+
+		r, _ := http.NewRequest("GET", h1.URL, nil)
+		mb, _, err := q1.FetchMulti([]string{"1", "2"}, r)
+
+		assert.Contains(t, err.Error(), "zzz:1: invalid http status: 500")
+		assert.Contains(t, err.Error(), "zzz:2: invalid http status: 500")
+		assert.Empty(t, mb)
+	})
+
+	t.Run("FetchMulti (resolver failure)", func(t *testing.T) {
+		q1 := cache.NewRemoteProvider(c1, cache.RemoteOption{
+			BatchResolver: &batchResolver{},
+		})
+
+		r, _ := http.NewRequest("GET", h1.URL, nil)
+		mb, _, err := q1.FetchMulti([]string{"1"}, r)
+
+		assert.Contains(t, err.Error(), "zzz:1: unknown batch")
+		assert.Empty(t, mb)
+	})
+}
+
+func TestObjectSplitter(t *testing.T) {
+	n := json.NewNode(strings.NewReader(`{"1":{"name":"foo"},"2":null}`))
+	x := &cache.ObjectSplitter{}
+
+	m, err := x.Split([]string{"zzz:1", "zzz:2", "zzz:3"}, n)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"zzz:1": []byte(`{"name":"foo"}`)}, m)
+
+	x = &cache.ObjectSplitter{Path: "data"}
+	_, err = x.Split([]string{"zzz:1"}, n)
+	assert.NotNil(t, err)
+}
+
+func TestArraySplitter(t *testing.T) {
+	n := json.NewNode(strings.NewReader(`[{"sku":"a-1","name":"foo"},{"sku":"b-2","name":"bar"}]`))
+	x := &cache.ArraySplitter{
+		Field: "sku",
+		ID:    func(key string) string { return strings.Replace(cache.Normalize(key, ""), "/", "-", 1) },
+	}
+
+	m, err := x.Split([]string{"zzz:a/1", "zzz:c/3"}, n)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"zzz:a/1": []byte(`{"sku":"a-1","name":"foo"}`)}, m)
+
+	n = json.NewNode(bytes.NewReader([]byte(`{}`)))
+	_, err = x.Split([]string{"zzz:a/1"}, n)
+	assert.NotNil(t, err)
+}
+
+type batchResolver struct {
+	path string
+}
+
+func (v *batchResolver) ResolveBatch(keys []string, r *http.Request) ([]*cache.Batch, error) {
+	if v.path == "" {
+		return nil, errors.New("unknown batch")
+	}
+
+	ids := make([]string, len(keys))
+	for i := range keys {
+		ids[i] = cache.Normalize(keys[i], "")
+	}
+
+	req := httpclone.Request(r)
+	req.URL.Path = v.path
+	req.URL.RawQuery = "ids=" + strings.Join(ids, ",")
+
+	return []*cache.Batch{{Keys: keys, Request: req}}, nil
+}
@@ -26,6 +26,12 @@ type Storage interface {
 	Delete(key string) error
 }
 
+// Scanner is the interface for cache backend implementation for enumerating cache keys and their expiration.
+type Scanner interface {
+	Scan(pattern string) ([]string, error)
+	TTL(key string) (time.Duration, error)
+}
+
 // Normalizer is the interface for normalizing cache key
 type Normalizer interface {
 	Normalize(key string) string
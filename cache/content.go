@@ -0,0 +1,116 @@
+package cache
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/bukalapak/ottoman/encoding/json"
+	"github.com/pkg/errors"
+)
+
+// ContentError is returned when the response body is rejected by the content checks of RemoteOption.
+type ContentError struct {
+	Err error
+}
+
+func (e *ContentError) Error() string {
+	return "invalid content: " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *ContentError) Unwrap() error {
+	return e.Err
+}
+
+// ValidJSON is a RemoteOption.Validate function ensuring the response body is valid JSON.
+func ValidJSON(b []byte) error {
+	if !json.Valid(b) {
+		return errors.New("invalid JSON body")
+	}
+
+	return nil
+}
+
+func (n RemoteOption) prepareRequest(r *http.Request) {
+	if !n.Gzip || r.Header.Get("Accept-Encoding") != "" {
+		return
+	}
+
+	if r.Header == nil {
+		r.Header = make(http.Header)
+	}
+
+	r.Header.Set("Accept-Encoding", "gzip")
+}
+
+// readBody reads the response body, enforcing content checks. A rejected body results in *ContentError.
+func (n RemoteOption) readBody(resp *http.Response) ([]byte, error) {
+	if err := n.checkContentType(resp.Header.Get("Content-Type")); err != nil {
+		return nil, &ContentError{Err: err}
+	}
+
+	if n.MaxBodySize > 0 && resp.ContentLength > n.MaxBodySize {
+		return nil, &ContentError{Err: n.errBodySize()}
+	}
+
+	var r io.Reader = resp.Body
+
+	if n.Gzip && strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		z, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, &ContentError{Err: errors.Wrap(err, "gzip")}
+		}
+		defer z.Close()
+
+		r = z
+	}
+
+	if n.MaxBodySize > 0 {
+		r = io.LimitReader(r, n.MaxBodySize+1)
+	}
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if n.MaxBodySize > 0 && int64(len(b)) > n.MaxBodySize {
+		return nil, &ContentError{Err: n.errBodySize()}
+	}
+
+	if n.Validate != nil {
+		if err := n.Validate(b); err != nil {
+			return nil, &ContentError{Err: err}
+		}
+	}
+
+	return b, nil
+}
+
+func (n RemoteOption) errBodySize() error {
+	return errors.Errorf("response body exceeds %d bytes", n.MaxBodySize)
+}
+
+func (n RemoteOption) checkContentType(s string) error {
+	if len(n.ContentTypes) == 0 {
+		return nil
+	}
+
+	t, _, err := mime.ParseMediaType(s)
+	if err != nil {
+		return errors.Errorf("invalid content type: %q", s)
+	}
+
+	for _, v := range n.ContentTypes {
+		v = strings.ToLower(v)
+
+		if v == t || (strings.HasSuffix(v, "/*") && strings.HasPrefix(t, strings.TrimSuffix(v, "*"))) {
+			return nil
+		}
+	}
+
+	return errors.Errorf("unexpected content type: %q", t)
+}
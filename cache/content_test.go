@@ -0,0 +1,142 @@
+package cache_test
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bukalapak/ottoman/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemoteProvider_Content(t *testing.T) {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/zoo":
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+			if r.Header.Get("Accept-Encoding") == "gzip" {
+				w.Header().Set("Content-Encoding", "gzip")
+
+				z := gzip.NewWriter(w)
+				io.WriteString(z, `{"zoo":"zac"}`)
+				z.Close()
+
+				return
+			}
+
+			io.WriteString(w, `{"zoo":"zac"}`)
+		case "/bad":
+			w.Header().Set("Content-Type", "text/html")
+			io.WriteString(w, `<html>`+strings.Repeat("x", 100)+`</html>`)
+		case "/boo":
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `<html></html>`)
+		}
+	}
+
+	h1 := httptest.NewServer(http.HandlerFunc(fn))
+	defer h1.Close()
+
+	z1 := newMemory()
+	c1 := cache.NewProvider(z1, "zzz")
+
+	fetch := func(key string, opt cache.RemoteOption) ([]byte, *cache.FetchInfo, error) {
+		opt.Resolver = &resolver{}
+		q1 := cache.NewRemoteProvider(c1, opt)
+
+		r, _ := http.NewRequest("GET", h1.URL, nil)
+		return q1.Fetch(key, r)
+	}
+
+	t.Run("Gzip", func(t *testing.T) {
+		b, n, err := fetch("zoo", cache.RemoteOption{Gzip: true, ContentTypes: []string{"application/json"}})
+
+		assert.Nil(t, err)
+		assert.Equal(t, []byte(`{"zoo":"zac"}`), b)
+		assert.Nil(t, n.Invalid)
+	})
+
+	t.Run("MaxBodySize", func(t *testing.T) {
+		b, n, err := fetch("bad", cache.RemoteOption{MaxBodySize: 64})
+
+		assert.Contains(t, err.Error(), "response body exceeds 64 bytes")
+		assert.Nil(t, b)
+		assert.Equal(t, http.StatusOK, n.StatusCode)
+		assert.Equal(t, err, n.Invalid)
+	})
+
+	t.Run("MaxBodySize (decoded)", func(t *testing.T) {
+		b, n, err := fetch("zoo", cache.RemoteOption{MaxBodySize: 8, Gzip: true})
+
+		assert.NotNil(t, err)
+		assert.Nil(t, b)
+		assert.NotNil(t, n.Invalid)
+	})
+
+	t.Run("ContentTypes", func(t *testing.T) {
+		b, n, err := fetch("bad", cache.RemoteOption{ContentTypes: []string{"application/json", "text/plain"}})
+
+		assert.Contains(t, err.Error(), `unexpected content type: "text/html"`)
+		assert.Nil(t, b)
+		assert.NotNil(t, n.Invalid)
+
+		b, _, err = fetch("bad", cache.RemoteOption{ContentTypes: []string{"text/*"}})
+		assert.Nil(t, err)
+		assert.NotNil(t, b)
+	})
+
+	t.Run("Validate", func(t *testing.T) {
+		b, n, err := fetch("boo", cache.RemoteOption{Validate: cache.ValidJSON})
+
+		var cerr *cache.ContentError
+
+		assert.True(t, errors.As(err, &cerr))
+		assert.Equal(t, "invalid content: invalid JSON body", err.Error())
+		assert.Nil(t, b)
+		assert.Equal(t, cerr, n.Invalid)
+	})
+
+	t.Run("FetchMulti", func(t *testing.T) {
+		q1 := cache.NewRemoteProvider(c1, cache.RemoteOption{
+			Resolver:     &resolver{},
+			MaxBodySize:  64,
+			ContentTypes: []string{"application/json"},
+			Validate:     cache.ValidJSON,
+		})
+
+		r, _ := http.NewRequest("GET", h1.URL, nil)
+		mb, mn, err := q1.FetchMulti([]string{"zoo", "bad", "boo"}, r)
+
+		assert.NotNil(t, err)
+		assert.Equal(t, []byte(`{"zoo":"zac"}`), mb["zzz:zoo"])
+		assert.NotContains(t, mb, "zzz:bad")
+		assert.NotContains(t, mb, "zzz:boo")
+		assert.Nil(t, mn["zzz:zoo"].Invalid)
+
+		for _, k := range []string{"zzz:bad", "zzz:boo"} {
+			assert.Equal(t, http.StatusOK, mn[k].StatusCode)
+			assert.NotNil(t, mn[k].Invalid)
+		}
+	})
+
+	t.Run("FetchStore (invalid)", func(t *testing.T) {
+		q1 := cache.NewRemoteProvider(c1, cache.RemoteOption{
+			Resolver:   &resolver{},
+			Expiration: time.Minute,
+			Validate:   cache.ValidJSON,
+		}).(cache.Storer)
+
+		r, _ := http.NewRequest("GET", h1.URL, nil)
+		_, n, err := q1.FetchStore("boo", r)
+
+		assert.NotNil(t, err)
+		assert.NotNil(t, n.Invalid)
+		assert.NotContains(t, z1.data, "zzz:boo")
+	})
+}
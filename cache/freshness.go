@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HeaderTTL derives cache expiration from the HTTP response header h, as seen by a shared cache at time now.
+// It honors Cache-Control s-maxage, max-age, no-store, no-cache and private directives, then Expires, adjusted by Age.
+// The returned bool reports whether h carries explicit caching information. A zero duration along with true means
+// the response must not be stored.
+func HeaderTTL(h http.Header, now time.Time) (time.Duration, bool) {
+	cc := parseCacheControl(h.Get("Cache-Control"))
+
+	for _, d := range []string{"no-store", "no-cache", "private"} {
+		if _, ok := cc[d]; ok {
+			return 0, true
+		}
+	}
+
+	age := headerSeconds(h.Get("Age"))
+
+	for _, d := range []string{"s-maxage", "max-age"} {
+		if v, ok := cc[d]; ok {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return 0, true
+			}
+
+			return freshness(time.Duration(n)*time.Second - age), true
+		}
+	}
+
+	if v := h.Get("Expires"); v != "" {
+		t, err := http.ParseTime(v)
+		if err != nil {
+			return 0, true
+		}
+
+		if d, err := http.ParseTime(h.Get("Date")); err == nil {
+			now = d
+		}
+
+		return freshness(t.Sub(now) - age), true
+	}
+
+	return 0, false
+}
+
+func freshness(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+
+	return d.Truncate(time.Second)
+}
+
+func headerSeconds(s string) time.Duration {
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+
+	return time.Duration(n) * time.Second
+}
+
+func parseCacheControl(s string) map[string]string {
+	m := make(map[string]string)
+
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+
+		if n := strings.SplitN(v, "=", 2); len(n) == 2 {
+			m[strings.ToLower(n[0])] = strings.Trim(n[1], `"`)
+		} else {
+			m[strings.ToLower(v)] = ""
+		}
+	}
+
+	return m
+}
@@ -0,0 +1,42 @@
+package cache_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bukalapak/ottoman/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeaderTTL(t *testing.T) {
+	now := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	data := []struct {
+		header http.Header
+		ttl    time.Duration
+		ok     bool
+	}{
+		{http.Header{}, 0, false},
+		{http.Header{"Cache-Control": {"max-age=60"}}, time.Minute, true},
+		{http.Header{"Cache-Control": {"public, max-age=60, s-maxage=120"}}, 2 * time.Minute, true},
+		{http.Header{"Cache-Control": {"max-age=60"}, "Age": {"20"}}, 40 * time.Second, true},
+		{http.Header{"Cache-Control": {"public"}}, 0, false},
+		{http.Header{"Cache-Control": {"max-age=60"}, "Age": {"90"}}, 0, true},
+		{http.Header{"Cache-Control": {"max-age=abc"}}, 0, true},
+		{http.Header{"Cache-Control": {"no-store, max-age=60"}}, 0, true},
+		{http.Header{"Cache-Control": {"private, max-age=60"}}, 0, true},
+		{http.Header{"Cache-Control": {"No-Cache"}}, 0, true},
+		{http.Header{"Expires": {"Tue, 01 Jan 2019 00:05:00 GMT"}}, 5 * time.Minute, true},
+		{http.Header{"Expires": {"Tue, 01 Jan 2019 00:05:00 GMT"}, "Date": {"Tue, 01 Jan 2019 00:04:00 GMT"}}, time.Minute, true},
+		{http.Header{"Expires": {"Tue, 01 Jan 2019 00:05:00 GMT"}, "Cache-Control": {"max-age=10"}}, 10 * time.Second, true},
+		{http.Header{"Expires": {"0"}}, 0, true},
+		{http.Header{"Expires": {"Mon, 31 Dec 2018 00:00:00 GMT"}}, 0, true},
+	}
+
+	for _, x := range data {
+		ttl, ok := cache.HeaderTTL(x.header, now)
+		assert.Equal(t, x.ttl, ttl, x.header)
+		assert.Equal(t, x.ok, ok, x.header)
+	}
+}
@@ -0,0 +1,193 @@
+package cache
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	httpclone "github.com/bukalapak/ottoman/http/clone"
+)
+
+// Targeter is the interface for choosing a remote origin. It's compatible with proxy.Targeter.
+// Targeters tracking outstanding requests, e.g. proxy.LeastTarget, are released once the request completes.
+type Targeter interface {
+	Target() *url.URL
+}
+
+// releaser is a Targeter tracking outstanding requests, compatible with proxy.Releaser.
+type releaser interface {
+	Targeter
+	Release(u *url.URL)
+}
+
+// HedgePolicy is the configuration for hedging slow remote requests.
+// When the origin does not respond within Delay, a second request is sent to the alternate Origin
+// and whichever returns first is used.
+type HedgePolicy struct {
+	// Delay is the latency threshold before sending the hedged request. Zero disables hedging.
+	Delay time.Duration
+
+	// Origin chooses the alternate origin of the hedged request, e.g. proxy.RingTarget.
+	Origin Targeter
+}
+
+// send sends the request to its origin, then to each of the fallback origins in order until one succeeds.
+func (p *remoteProvider) send(r *http.Request) (*http.Response, int, error) {
+	resp, n, err := p.hedge(r)
+
+	for _, o := range p.option.Fallbacks {
+		if !retryable(resp, err) || !replayable(r) {
+			break
+		}
+
+		u := o.Target()
+		if u == nil {
+			continue
+		}
+
+		discard(resp)
+		resp, n, err = p.hedge(retarget(r, u))
+		releaseAfter(o, u, resp, err)
+	}
+
+	return resp, n, err
+}
+
+type hedgeResult struct {
+	resp *http.Response
+	n    int
+	err  error
+	i    int
+}
+
+func (p *remoteProvider) hedge(r *http.Request) (*http.Response, int, error) {
+	h := p.option.Hedge
+	if h.Delay <= 0 || h.Origin == nil || !replayable(r) {
+		return p.roundTrip(r)
+	}
+
+	ch := make(chan hedgeResult, 2)
+	cancels := make([]context.CancelFunc, 0, 2)
+
+	launch := func(req *http.Request, after func(resp *http.Response, err error)) {
+		ctx, cancel := context.WithCancel(req.Context())
+		cancels = append(cancels, cancel)
+
+		go func(i int) {
+			resp, n, err := p.roundTrip(req.WithContext(ctx))
+			after(resp, err)
+			ch <- hedgeResult{resp: resp, n: n, err: err, i: i}
+		}(len(cancels) - 1)
+	}
+
+	finish := func(x hedgeResult, pending int) (*http.Response, int, error) {
+		for i, cancel := range cancels {
+			if i != x.i {
+				cancel()
+			}
+		}
+
+		go func() {
+			for ; pending > 0; pending-- {
+				discard((<-ch).resp)
+			}
+		}()
+
+		if x.err != nil {
+			cancels[x.i]()
+			return x.resp, x.n, x.err
+		}
+
+		x.resp.Body = &closeBody{ReadCloser: x.resp.Body, fn: cancels[x.i]}
+
+		return x.resp, x.n, nil
+	}
+
+	launch(r, func(*http.Response, error) {})
+
+	t := time.NewTimer(h.Delay)
+	defer t.Stop()
+
+	select {
+	case x := <-ch:
+		return finish(x, 0)
+	case <-t.C:
+	}
+
+	u := h.Origin.Target()
+	if u == nil {
+		return finish(<-ch, 0)
+	}
+
+	launch(retarget(r, u), func(resp *http.Response, err error) {
+		releaseAfter(h.Origin, u, resp, err)
+	})
+
+	x := <-ch
+	if !retryable(x.resp, x.err) {
+		return finish(x, 1)
+	}
+
+	discard(x.resp)
+
+	return finish(<-ch, 0)
+}
+
+// retarget returns a copy of the request directed to the origin u.
+func retarget(r *http.Request, u *url.URL) *http.Request {
+	req := httpclone.Request(r)
+	req.URL.Scheme = u.Scheme
+	req.URL.Host = u.Host
+	req.Host = u.Host
+
+	if r.GetBody != nil {
+		if body, err := r.GetBody(); err == nil {
+			req.Body = body
+		}
+	}
+
+	return req
+}
+
+func replayable(r *http.Request) bool {
+	return r.Body == nil || r.Body == http.NoBody || r.GetBody != nil
+}
+
+func discard(resp *http.Response) {
+	if resp != nil {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+}
+
+// releaseAfter releases the origin u chosen by t once the response body is closed, or immediately on error.
+func releaseAfter(t Targeter, u *url.URL, resp *http.Response, err error) {
+	x, ok := t.(releaser)
+	if !ok {
+		return
+	}
+
+	if err != nil || resp == nil {
+		x.Release(u)
+		return
+	}
+
+	resp.Body = &closeBody{ReadCloser: resp.Body, fn: func() { x.Release(u) }}
+}
+
+// closeBody calls fn once the body is closed.
+type closeBody struct {
+	io.ReadCloser
+	fn   func()
+	once sync.Once
+}
+
+func (b *closeBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.fn)
+
+	return err
+}
@@ -0,0 +1,178 @@
+package cache_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bukalapak/ottoman/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemoteProvider_Hedge(t *testing.T) {
+	var slowHits, fastHits int32
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&slowHits, 1)
+
+		if r.URL.Path == "/zoo" {
+			select {
+			case <-time.After(time.Second):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		io.WriteString(w, `{"origin":"slow"}`)
+	}))
+	defer slow.Close()
+
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fastHits, 1)
+		io.WriteString(w, `{"origin":"fast"}`)
+	}))
+	defer fast.Close()
+
+	o := &releasingOrigin{origin: newOrigin(fast.URL)}
+
+	q1 := cache.NewRemoteProvider(cache.NewProvider(newSample(), "zzz"), cache.RemoteOption{
+		Resolver: &resolver{},
+		Hedge: cache.HedgePolicy{
+			Delay:  20 * time.Millisecond,
+			Origin: o,
+		},
+	})
+
+	t.Run("Hedged", func(t *testing.T) {
+		r, _ := http.NewRequest("GET", slow.URL, nil)
+		b, n, err := q1.Fetch("zoo", r)
+
+		assert.Nil(t, err)
+		assert.Equal(t, []byte(`{"origin":"fast"}`), b)
+		assert.Equal(t, fast.URL, n.Origin)
+		assert.Equal(t, fast.URL+"/zoo", n.RemoteURL)
+		assert.True(t, n.Duration < time.Second)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&o.targeted))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&o.released))
+	})
+
+	t.Run("Not hedged", func(t *testing.T) {
+		atomic.StoreInt32(&fastHits, 0)
+
+		r, _ := http.NewRequest("GET", slow.URL, nil)
+		b, n, err := q1.Fetch("boo", r)
+
+		assert.Nil(t, err)
+		assert.Equal(t, []byte(`{"origin":"slow"}`), b)
+		assert.Equal(t, slow.URL, n.Origin)
+		assert.Zero(t, atomic.LoadInt32(&fastHits))
+	})
+}
+
+func TestRemoteProvider_Fallbacks(t *testing.T) {
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer bad.Close()
+
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"origin":"good"}`)
+	}))
+	defer good.Close()
+
+	t.Run("Fallback", func(t *testing.T) {
+		q1 := cache.NewRemoteProvider(cache.NewProvider(newSample(), "zzz"), cache.RemoteOption{
+			Resolver:  &resolver{},
+			Fallbacks: []cache.Targeter{newOrigin(bad.URL), newOrigin(good.URL)},
+		})
+
+		r, _ := http.NewRequest("GET", bad.URL, nil)
+		b, n, err := q1.Fetch("zoo", r)
+
+		assert.Nil(t, err)
+		assert.Equal(t, []byte(`{"origin":"good"}`), b)
+		assert.Equal(t, good.URL, n.Origin)
+	})
+
+	t.Run("Fallback (release)", func(t *testing.T) {
+		o1 := &releasingOrigin{origin: newOrigin(bad.URL)}
+		o2 := &releasingOrigin{origin: newOrigin(good.URL)}
+
+		q1 := cache.NewRemoteProvider(cache.NewProvider(newSample(), "zzz"), cache.RemoteOption{
+			Resolver:  &resolver{},
+			Fallbacks: []cache.Targeter{o1, o2},
+		})
+
+		r, _ := http.NewRequest("GET", bad.URL, nil)
+		_, _, err := q1.Fetch("zoo", r)
+
+		assert.Nil(t, err)
+
+		for _, o := range []*releasingOrigin{o1, o2} {
+			assert.Equal(t, int32(1), atomic.LoadInt32(&o.targeted))
+			assert.Equal(t, int32(1), atomic.LoadInt32(&o.released))
+		}
+	})
+
+	t.Run("Fallback (exhausted)", func(t *testing.T) {
+		q1 := cache.NewRemoteProvider(cache.NewProvider(newSample(), "zzz"), cache.RemoteOption{
+			Resolver:  &resolver{},
+			Fallbacks: []cache.Targeter{newOrigin(bad.URL)},
+		})
+
+		r, _ := http.NewRequest("GET", bad.URL, nil)
+		b, n, err := q1.Fetch("zoo", r)
+
+		assert.NotNil(t, err)
+		assert.Nil(t, b)
+		assert.Equal(t, http.StatusBadGateway, n.StatusCode)
+		assert.Equal(t, bad.URL, n.Origin)
+	})
+
+	t.Run("Fallback (network failure)", func(t *testing.T) {
+		q1 := cache.NewRemoteProvider(cache.NewProvider(newSample(), "zzz"), cache.RemoteOption{
+			Resolver:  &resolver{},
+			Fallbacks: []cache.Targeter{newOrigin(good.URL)},
+		})
+
+		r, _ := http.NewRequest("GET", "http://127.0.0.1:1", nil)
+		b, n, err := q1.Fetch("zoo", r)
+
+		assert.Nil(t, err)
+		assert.Equal(t, []byte(`{"origin":"good"}`), b)
+		assert.Equal(t, good.URL, n.Origin)
+	})
+}
+
+type origin struct {
+	u *url.URL
+}
+
+func newOrigin(s string) *origin {
+	u, _ := url.Parse(s)
+	return &origin{u: u}
+}
+
+func (o *origin) Target() *url.URL {
+	return o.u
+}
+
+// releasingOrigin is an origin tracking outstanding requests, like proxy.LeastTarget.
+type releasingOrigin struct {
+	*origin
+	targeted int32
+	released int32
+}
+
+func (o *releasingOrigin) Target() *url.URL {
+	atomic.AddInt32(&o.targeted, 1)
+	return o.origin.Target()
+}
+
+func (o *releasingOrigin) Release(u *url.URL) {
+	atomic.AddInt32(&o.released, 1)
+}
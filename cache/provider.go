@@ -1,9 +1,13 @@
 package cache
 
 import (
+	"errors"
 	"time"
 )
 
+// ErrScanUnsupported is returned when the cache backend does not implement Scanner.
+var ErrScanUnsupported = errors.New("cache: scan is not supported by the storage")
+
 // Provider wraps Storage interface with additional functionalities.
 type Provider interface {
 	Storage
@@ -61,3 +65,23 @@ func (p *provider) ReadMulti(keys []string) (map[string][]byte, error) {
 func (p *provider) Delete(key string) error {
 	return p.engine.Delete(p.Normalize(key))
 }
+
+// Scan returns cache keys matching given pattern within the provider namespace.
+func (p *provider) Scan(pattern string) ([]string, error) {
+	z, ok := p.engine.(Scanner)
+	if !ok {
+		return nil, ErrScanUnsupported
+	}
+
+	return z.Scan(p.Normalize(pattern))
+}
+
+// TTL returns the remaining time to live of given key.
+func (p *provider) TTL(key string) (time.Duration, error) {
+	z, ok := p.engine.(Scanner)
+	if !ok {
+		return 0, ErrScanUnsupported
+	}
+
+	return z.TTL(p.Normalize(key))
+}
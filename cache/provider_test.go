@@ -2,6 +2,8 @@ package cache_test
 
 import (
 	"errors"
+	"path"
+	"sort"
 	"testing"
 	"time"
 
@@ -70,6 +72,31 @@ func TestProvider(t *testing.T) {
 		assert.NotNil(t, err)
 	})
 
+	t.Run("Scan", func(t *testing.T) {
+		keys, err := c1.(cache.Scanner).Scan("*")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"zzz:boo", "zzz:foo"}, keys)
+	})
+
+	t.Run("Scan (unsupported)", func(t *testing.T) {
+		_, err := c2.(cache.Scanner).Scan("*")
+		assert.Equal(t, cache.ErrScanUnsupported, err)
+	})
+
+	t.Run("TTL", func(t *testing.T) {
+		d, err := c1.(cache.Scanner).TTL("foo")
+		assert.Nil(t, err)
+		assert.Equal(t, time.Minute, d)
+
+		_, err = c1.(cache.Scanner).TTL("unknown")
+		assert.NotNil(t, err)
+	})
+
+	t.Run("TTL (unsupported)", func(t *testing.T) {
+		_, err := c2.(cache.Scanner).TTL("foo")
+		assert.Equal(t, cache.ErrScanUnsupported, err)
+	})
+
 	t.Run("Delete", func(t *testing.T) {
 		err := c1.Delete("foo")
 		assert.Nil(t, err)
@@ -117,6 +144,28 @@ func (m *sample) ReadMulti(keys []string) (map[string][]byte, error) {
 	return z, nil
 }
 
+func (m *sample) Scan(pattern string) ([]string, error) {
+	var keys []string
+
+	for k := range m.data {
+		if ok, _ := path.Match(pattern, k); ok {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+func (m *sample) TTL(key string) (time.Duration, error) {
+	if _, ok := m.data[key]; ok {
+		return time.Minute, nil
+	}
+
+	return 0, errors.New("unknown cache")
+}
+
 func (m *sample) Delete(key string) error {
 	delete(m.data, key)
 	return nil
@@ -1,19 +1,27 @@
 package cache
 
 import (
-	"io"
+	"context"
 	"net/http"
+	"sync"
 	"time"
 
+	"github.com/bukalapak/ottoman/encoding/json"
 	multierror "github.com/hashicorp/go-multierror"
 	"github.com/pkg/errors"
 )
 
 // FetchInfo is the container for the information data from a backend.
 type FetchInfo struct {
-	RemoteURL  string
-	StatusCode int
-	Duration   time.Duration
+	RemoteURL    string
+	Origin       string
+	StatusCode   int
+	Duration     time.Duration
+	Attempts     int
+	Invalid      error
+	ETag         string
+	LastModified string
+	TTL          time.Duration
 }
 
 // Fetcher is the interface for getting cache data from remote backend based on given key(s).
@@ -22,6 +30,13 @@ type Fetcher interface {
 	FetchMulti(keys []string, r *http.Request) (map[string][]byte, map[string]*FetchInfo, error)
 }
 
+// Storer is the interface for getting cache data from remote backend and storing it following HTTP caching semantics.
+// The RemoteProvider returned by NewRemoteProvider implements Storer, e.g. p.(cache.Storer).
+type Storer interface {
+	FetchStore(key string, r *http.Request) ([]byte, *FetchInfo, error)
+	FetchStoreMulti(keys []string, r *http.Request) (map[string][]byte, map[string]*FetchInfo, error)
+}
+
 // Resolver is the interface for resolving cache key to http request.
 type Resolver interface {
 	Resolve(key string, r *http.Request) (*http.Request, error)
@@ -39,6 +54,42 @@ type RemoteOption struct {
 	Transport http.RoundTripper
 	Timeout   time.Duration
 	Resolver  Resolver
+
+	// Expiration is used as FetchInfo.TTL when the response has no explicit caching information.
+	// Zero means such response is not stored by FetchStore.
+	Expiration time.Duration
+
+	// MaxConcurrency limits the number of concurrent remote requests of a multi fetch. Zero means unlimited.
+	MaxConcurrency int
+
+	// Retry is the retry policy for failed remote requests.
+	Retry RetryPolicy
+
+	// BatchResolver enables FetchMulti to fetch multiple keys using batched requests.
+	// The responses are mapped back to each key using Splitter, default to ObjectSplitter.
+	BatchResolver BatchResolver
+	Splitter      Splitter
+
+	// MaxBatchSize limits the number of keys passed to BatchResolver at once. Zero means unlimited.
+	MaxBatchSize int
+
+	// MaxBodySize limits the size of response body, after decoding. Zero means unlimited.
+	MaxBodySize int64
+
+	// ContentTypes restricts the accepted response media types, e.g. "application/json" or "text/*".
+	ContentTypes []string
+
+	// Gzip requests gzip encoded response explicitly and decodes it.
+	Gzip bool
+
+	// Validate checks the response body before it is returned or stored, e.g. ValidJSON.
+	Validate func(b []byte) error
+
+	// Hedge is the hedging policy for slow remote requests.
+	Hedge HedgePolicy
+
+	// Fallbacks are the alternate origins tried in order when a remote request fails.
+	Fallbacks []Targeter
 }
 
 func (n RemoteOption) httpClient() *http.Client {
@@ -67,6 +118,7 @@ func (n RemoteOption) httpTimeout() time.Duration {
 type remoteProvider struct {
 	Provider
 	option RemoteOption
+	client *http.Client
 }
 
 // NewRemoteProvider returns RemoteProvider from a Provider and RemoteOption.
@@ -74,11 +126,12 @@ func NewRemoteProvider(p Provider, opt RemoteOption) RemoteProvider {
 	return &remoteProvider{
 		Provider: p,
 		option:   opt,
+		client:   opt.httpClient(),
 	}
 }
 
 func (p *remoteProvider) Fetch(key string, r *http.Request) ([]byte, *FetchInfo, error) {
-	req, err := p.Resolve(p.Normalize(key), r)
+	req, err := p.resolve(p.Normalize(key), r)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -86,80 +139,356 @@ func (p *remoteProvider) Fetch(key string, r *http.Request) ([]byte, *FetchInfo,
 	return p.fetchRequest(req)
 }
 
+// resolve resolves the already normalized key and propagates the incoming request context,
+// unless the resolver has set its own.
+func (p *remoteProvider) resolve(key string, r *http.Request) (*http.Request, error) {
+	req, err := p.option.Resolver.Resolve(key, r)
+	if err != nil {
+		return nil, err
+	}
+
+	return withContext(req, r), nil
+}
+
+func withContext(req, r *http.Request) *http.Request {
+	if r != nil && req.Context() == context.Background() {
+		return req.WithContext(r.Context())
+	}
+
+	return req
+}
+
 func (p *remoteProvider) fetchRequest(r *http.Request) ([]byte, *FetchInfo, error) {
-	c := p.option.httpClient()
+	b, n, err := p.doRequest(r)
+	if err != nil || n.StatusCode == http.StatusOK {
+		return b, n, err
+	}
+
+	return nil, n, errors.Errorf("invalid http status: %d %s", n.StatusCode, http.StatusText(n.StatusCode))
+}
+
+func (p *remoteProvider) doRequest(r *http.Request) ([]byte, *FetchInfo, error) {
 	now := time.Now()
 
-	resp, err := c.Do(r)
+	p.option.prepareRequest(r)
+
+	resp, attempts, err := p.send(r)
 	if err != nil {
 		return nil, nil, err
 	}
 	defer resp.Body.Close()
 
+	u := r.URL
+	if resp.Request != nil {
+		u = resp.Request.URL
+	}
+
+	n := &FetchInfo{
+		RemoteURL:    u.String(),
+		Origin:       u.Scheme + "://" + u.Host,
+		StatusCode:   resp.StatusCode,
+		Attempts:     attempts,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+
+	if ttl, ok := HeaderTTL(resp.Header, now); ok {
+		n.TTL = ttl
+	} else {
+		n.TTL = p.option.Expiration
+	}
+
 	if resp.StatusCode != http.StatusOK {
-		return nil, &FetchInfo{
-			RemoteURL:  r.URL.String(),
-			StatusCode: resp.StatusCode,
-			Duration:   time.Since(now),
-		}, errors.New("invalid http status: " + resp.Status)
+		n.Duration = time.Since(now)
+		return nil, n, nil
+	}
+
+	b, err := p.option.readBody(resp)
+	n.Duration = time.Since(now)
+
+	var cerr *ContentError
+	if errors.As(err, &cerr) {
+		n.Invalid = cerr
+	}
+
+	return b, n, err
+}
+
+// roundTrip sends the request, retrying according to the retry policy. It returns the number of attempts made.
+func (p *remoteProvider) roundTrip(r *http.Request) (*http.Response, int, error) {
+	limit := p.option.Retry.maxAttempt()
+
+	if r.Body != nil && r.Body != http.NoBody && r.GetBody == nil {
+		limit = 1
 	}
 
-	b, err := io.ReadAll(resp.Body)
+	for i := 1; ; i++ {
+		if i > 1 && r.GetBody != nil {
+			body, err := r.GetBody()
+			if err != nil {
+				return nil, i - 1, err
+			}
+
+			r.Body = body
+		}
+
+		resp, err := p.client.Do(r)
+		if i >= limit || !retryable(resp, err) {
+			return resp, i, err
+		}
 
-	return b, &FetchInfo{
-		RemoteURL:  r.URL.String(),
-		StatusCode: resp.StatusCode,
-		Duration:   time.Since(now),
-	}, err
+		d := p.option.Retry.backoff(i, resp)
+		discard(resp)
+
+		if err := sleep(r.Context(), d); err != nil {
+			return nil, i, err
+		}
+	}
 }
 
+// FetchMulti is batch version of Fetch. When BatchResolver is configured, keys are fetched using batched requests.
 func (p *remoteProvider) FetchMulti(keys []string, r *http.Request) (map[string][]byte, map[string]*FetchInfo, error) {
-	ks := p.NormalizeMulti(keys)
+	if p.option.BatchResolver != nil {
+		return p.fetchBatch(keys, r)
+	}
 
-	mb := make(map[string][]byte)
-	mn := make(map[string]*FetchInfo)
+	return p.fetchMulti(keys, r, p.Fetch)
+}
+
+// FetchStore fetches the key from remote backend and stores the result using expiration derived from the response header.
+// Validators are stored alongside the body, so subsequent calls revalidate using If-None-Match/If-Modified-Since
+// and a 304 response refreshes the stored entry.
+func (p *remoteProvider) FetchStore(key string, r *http.Request) ([]byte, *FetchInfo, error) {
+	key = p.Normalize(key)
 
-	type fetchPayload struct {
-		body []byte
-		info *FetchInfo
+	req, err := p.resolve(key, r)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	ec := make(chan error)
-	bc := make(chan map[string]fetchPayload)
+	v := p.readValidator(key)
+	v.apply(req)
 
-	for _, k := range ks {
-		go func(key string) {
-			b, n, err := p.Fetch(key, r)
-			if err != nil {
-				ec <- errors.Wrap(err, key)
-			} else {
-				bc <- map[string]fetchPayload{
-					key: {
-						body: b,
-						info: n,
-					},
-				}
+	b, n, err := p.doRequest(req)
+	if err != nil {
+		return nil, n, err
+	}
+
+	if n.StatusCode == http.StatusNotModified {
+		if b, err = p.Read(key); err != nil || len(b) == 0 {
+			if req, err = p.resolve(key, r); err != nil {
+				return nil, nil, err
+			}
+
+			(&validator{}).apply(req)
+
+			if b, n, err = p.doRequest(req); err != nil {
+				return nil, n, err
+			}
+
+			if n.StatusCode == http.StatusNotModified {
+				return nil, n, errors.New("not modified response without stored body")
 			}
-		}(k)
+		} else {
+			n.ETag = fallback(n.ETag, v.ETag)
+			n.LastModified = fallback(n.LastModified, v.LastModified)
+		}
+	}
+
+	if n.StatusCode != http.StatusOK && n.StatusCode != http.StatusNotModified {
+		return nil, n, errors.Errorf("invalid http status: %d %s", n.StatusCode, http.StatusText(n.StatusCode))
 	}
 
-	var mrr *multierror.Error
+	if n.TTL > 0 {
+		if err := p.store(key, b, n); err != nil {
+			return b, n, err
+		}
+	}
+
+	return b, n, nil
+}
+
+func (p *remoteProvider) FetchStoreMulti(keys []string, r *http.Request) (map[string][]byte, map[string]*FetchInfo, error) {
+	return p.fetchMulti(keys, r, p.FetchStore)
+}
 
-	for i := 0; i < len(ks); i++ {
+func (p *remoteProvider) store(key string, b []byte, n *FetchInfo) error {
+	if err := p.Write(key, b, n.TTL); err != nil {
+		return err
+	}
+
+	if n.ETag == "" && n.LastModified == "" {
+		return nil
+	}
+
+	v, err := json.Marshal(&validator{ETag: n.ETag, LastModified: n.LastModified})
+	if err != nil {
+		return err
+	}
+
+	return p.Write(validatorKey(key), v, n.TTL)
+}
+
+func (p *remoteProvider) readValidator(key string) *validator {
+	v := &validator{}
+
+	if b, err := p.Read(validatorKey(key)); err == nil && len(b) != 0 {
+		json.Unmarshal(b, v)
+	}
+
+	return v
+}
+
+type fetchFunc func(key string, r *http.Request) ([]byte, *FetchInfo, error)
+
+func (p *remoteProvider) fetchMulti(keys []string, r *http.Request, fn fetchFunc) (map[string][]byte, map[string]*FetchInfo, error) {
+	ks := p.NormalizeMulti(keys)
+	c := newCollector()
+
+	failed := p.run(r, len(ks), func(i int) {
+		b, n, err := fn(ks[i], r)
+		c.add(ks[i], b, n, err)
+	})
+
+	for _, i := range failed {
+		c.add(ks[i], nil, nil, r.Context().Err())
+	}
+
+	return c.result()
+}
+
+// run calls fn for each of n tasks, bounded by MaxConcurrency. It returns the tasks skipped due to context cancellation.
+func (p *remoteProvider) run(r *http.Request, n int, fn func(i int)) []int {
+	ctx := context.Background()
+	if r != nil {
+		ctx = r.Context()
+	}
+
+	var wg sync.WaitGroup
+	var skipped []int
+
+	sem := make(chan struct{}, p.maxConcurrency(n))
+
+	for i := 0; i < n; i++ {
 		select {
-		case kb := <-bc:
-			for k, v := range kb {
-				mb[k] = v.body
-				mn[k] = v.info
-			}
-		case err := <-ec:
-			mrr = multierror.Append(mrr, err)
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			skipped = append(skipped, i)
+			continue
 		}
+
+		wg.Add(1)
+
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			fn(i)
+		}(i)
+	}
+
+	wg.Wait()
+
+	return skipped
+}
+
+func (p *remoteProvider) maxConcurrency(n int) int {
+	if p.option.MaxConcurrency > 0 && p.option.MaxConcurrency < n {
+		return p.option.MaxConcurrency
+	}
+
+	if n == 0 {
+		return 1
 	}
 
-	return mb, mn, mrr.ErrorOrNil()
+	return n
 }
 
+// Resolve normalizes the key into the provider namespace before passing it to RemoteOption.Resolver,
+// so resolvers always receive namespaced keys. Fetch methods normalize keys once and skip this step.
 func (p *remoteProvider) Resolve(key string, r *http.Request) (*http.Request, error) {
 	return p.option.Resolver.Resolve(p.Normalize(key), r)
 }
+
+type collector struct {
+	mu  sync.Mutex
+	mb  map[string][]byte
+	mn  map[string]*FetchInfo
+	mrr *multierror.Error
+}
+
+func newCollector() *collector {
+	return &collector{
+		mb: make(map[string][]byte),
+		mn: make(map[string]*FetchInfo),
+	}
+}
+
+func (c *collector) add(key string, b []byte, n *FetchInfo, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if n != nil {
+		c.mn[key] = n
+	}
+
+	if err != nil {
+		c.mrr = multierror.Append(c.mrr, errors.Wrap(err, key))
+		return
+	}
+
+	c.mb[key] = b
+}
+
+func (c *collector) fail(keys []string, err error) {
+	for _, k := range keys {
+		c.add(k, nil, nil, err)
+	}
+}
+
+func (c *collector) result() (map[string][]byte, map[string]*FetchInfo, error) {
+	return c.mb, c.mn, c.mrr.ErrorOrNil()
+}
+
+type validator struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// conditionalHeaders are the request headers making the request conditional.
+var conditionalHeaders = []string{"If-None-Match", "If-Modified-Since", "If-Match", "If-Unmodified-Since", "If-Range"}
+
+// apply replaces the conditional headers of the request, possibly copied from the incoming request, with the
+// stored validators.
+func (v *validator) apply(r *http.Request) {
+	r.Header = r.Header.Clone()
+	if r.Header == nil {
+		r.Header = make(http.Header)
+	}
+
+	for _, k := range conditionalHeaders {
+		r.Header.Del(k)
+	}
+
+	if v.ETag != "" {
+		r.Header.Set("If-None-Match", v.ETag)
+	}
+
+	if v.LastModified != "" {
+		r.Header.Set("If-Modified-Since", v.LastModified)
+	}
+}
+
+func fallback(s, def string) string {
+	if s == "" {
+		return def
+	}
+
+	return s
+}
+
+func validatorKey(key string) string {
+	return key + ":validator"
+}
@@ -1,9 +1,12 @@
 package cache_test
 
 import (
+	"context"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -91,6 +94,292 @@ func TestRemoteProvider(t *testing.T) {
 	})
 }
 
+func TestRemoteProvider_FetchStore(t *testing.T) {
+	var hits int32
+
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+
+		switch r.URL.Path {
+		case "/zoo":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set("Last-Modified", "Tue, 01 Jan 2019 00:00:00 GMT")
+
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.Header().Set("Cache-Control", "max-age=120")
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+
+			io.WriteString(w, `{"zoo":"zac"}`)
+		case "/bad":
+			w.Header().Set("Cache-Control", "no-store")
+			io.WriteString(w, `{"bad":"bat"}`)
+		case "/boo":
+			io.WriteString(w, `{"boo":"bah"}`)
+		case "/nob":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.WriteHeader(http.StatusNotModified)
+		}
+	}
+
+	h1 := httptest.NewServer(http.HandlerFunc(fn))
+	defer h1.Close()
+
+	newProvider := func(z cache.Storage, opt cache.RemoteOption) cache.Storer {
+		opt.Resolver = &resolver{}
+		return cache.NewRemoteProvider(cache.NewProvider(z, "zzz"), opt).(cache.Storer)
+	}
+
+	t.Run("FetchStore", func(t *testing.T) {
+		z1 := newMemory()
+		q1 := newProvider(z1, cache.RemoteOption{})
+
+		r, _ := http.NewRequest("GET", h1.URL, nil)
+		b, n, err := q1.FetchStore("zoo", r)
+
+		assert.Nil(t, err)
+		assert.Equal(t, []byte(`{"zoo":"zac"}`), b)
+		assert.Equal(t, http.StatusOK, n.StatusCode)
+		assert.Equal(t, `"v1"`, n.ETag)
+		assert.Equal(t, time.Minute, n.TTL)
+		assert.Equal(t, `{"zoo":"zac"}`, z1.data["zzz:zoo"].value)
+		assert.Equal(t, time.Minute, z1.data["zzz:zoo"].ttl)
+		assert.Contains(t, z1.data["zzz:zoo:validator"].value, `v1`)
+
+		b, n, err = q1.FetchStore("zoo", r)
+
+		assert.Nil(t, err)
+		assert.Equal(t, []byte(`{"zoo":"zac"}`), b)
+		assert.Equal(t, http.StatusNotModified, n.StatusCode)
+		assert.Equal(t, 2*time.Minute, n.TTL)
+		assert.Equal(t, 2*time.Minute, z1.data["zzz:zoo"].ttl)
+		assert.Equal(t, 2*time.Minute, z1.data["zzz:zoo:validator"].ttl)
+	})
+
+	t.Run("FetchStore (missing body)", func(t *testing.T) {
+		z1 := newMemory()
+		q1 := newProvider(z1, cache.RemoteOption{})
+
+		r, _ := http.NewRequest("GET", h1.URL, nil)
+		q1.FetchStore("zoo", r)
+		z1.Delete("zzz:zoo")
+
+		atomic.StoreInt32(&hits, 0)
+		b, n, err := q1.FetchStore("zoo", r)
+
+		assert.Nil(t, err)
+		assert.Equal(t, []byte(`{"zoo":"zac"}`), b)
+		assert.Equal(t, http.StatusOK, n.StatusCode)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+		assert.Equal(t, `{"zoo":"zac"}`, z1.data["zzz:zoo"].value)
+	})
+
+	t.Run("FetchStore (conditional request)", func(t *testing.T) {
+		z1 := newMemory()
+		q1 := newProvider(z1, cache.RemoteOption{})
+
+		r, _ := http.NewRequest("GET", h1.URL, nil)
+		r.Header.Set("If-None-Match", `"v1"`)
+		r.Header.Set("If-Modified-Since", "Tue, 01 Jan 2019 00:00:00 GMT")
+
+		b, n, err := q1.FetchStore("zoo", r)
+
+		assert.Nil(t, err)
+		assert.Equal(t, []byte(`{"zoo":"zac"}`), b)
+		assert.Equal(t, http.StatusOK, n.StatusCode)
+		assert.Equal(t, `{"zoo":"zac"}`, z1.data["zzz:zoo"].value)
+		assert.Equal(t, `"v1"`, r.Header.Get("If-None-Match"))
+	})
+
+	t.Run("FetchStore (not modified without body)", func(t *testing.T) {
+		z1 := newMemory()
+		q1 := newProvider(z1, cache.RemoteOption{})
+
+		r, _ := http.NewRequest("GET", h1.URL, nil)
+		b, n, err := q1.FetchStore("nob", r)
+
+		assert.NotNil(t, err)
+		assert.Nil(t, b)
+		assert.Equal(t, http.StatusNotModified, n.StatusCode)
+		assert.NotContains(t, z1.data, "zzz:nob")
+	})
+
+	t.Run("FetchStore (not cacheable)", func(t *testing.T) {
+		z1 := newMemory()
+		q1 := newProvider(z1, cache.RemoteOption{Expiration: time.Hour})
+
+		r, _ := http.NewRequest("GET", h1.URL, nil)
+		b, n, err := q1.FetchStore("bad", r)
+
+		assert.Nil(t, err)
+		assert.Equal(t, []byte(`{"bad":"bat"}`), b)
+		assert.Zero(t, n.TTL)
+		assert.NotContains(t, z1.data, "zzz:bad")
+	})
+
+	t.Run("FetchStore (default expiration)", func(t *testing.T) {
+		z1 := newMemory()
+		q1 := newProvider(z1, cache.RemoteOption{Expiration: time.Hour})
+
+		r, _ := http.NewRequest("GET", h1.URL, nil)
+		b, n, err := q1.FetchStore("boo", r)
+
+		assert.Nil(t, err)
+		assert.Equal(t, []byte(`{"boo":"bah"}`), b)
+		assert.Equal(t, time.Hour, n.TTL)
+		assert.Equal(t, time.Hour, z1.data["zzz:boo"].ttl)
+		assert.NotContains(t, z1.data, "zzz:boo:validator")
+	})
+
+	t.Run("FetchStore (unknown key)", func(t *testing.T) {
+		q1 := newProvider(newMemory(), cache.RemoteOption{})
+
+		r, _ := http.NewRequest("GET", h1.URL, nil)
+		b, n, err := q1.FetchStore("unknown", r)
+
+		assert.NotNil(t, err)
+		assert.Nil(t, b)
+		assert.Nil(t, n)
+	})
+
+	t.Run("FetchStoreMulti", func(t *testing.T) {
+		z1 := newMemory()
+		q1 := newProvider(z1, cache.RemoteOption{})
+
+		r, _ := http.NewRequest("GET", h1.URL, nil)
+		mb, mn, err := q1.FetchStoreMulti([]string{"zoo", "bad"}, r)
+
+		assert.Nil(t, err)
+		assert.Len(t, mb, 2)
+		assert.Equal(t, time.Minute, mn["zzz:zoo"].TTL)
+		assert.Zero(t, mn["zzz:bad"].TTL)
+		assert.Contains(t, z1.data, "zzz:zoo")
+		assert.NotContains(t, z1.data, "zzz:bad")
+	})
+}
+
+func TestRemoteProvider_FetchMulti(t *testing.T) {
+	var hits, active, peak int32
+
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&hits, 1)
+
+		if v := atomic.AddInt32(&active, 1); v > atomic.LoadInt32(&peak) {
+			atomic.StoreInt32(&peak, v)
+		}
+		defer atomic.AddInt32(&active, -1)
+
+		switch r.URL.Path {
+		case "/zoo":
+			time.Sleep(10 * time.Millisecond)
+			io.WriteString(w, `{"zoo":"zac"}`)
+		case "/bad":
+			if n%2 == 1 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+
+			io.WriteString(w, `{"bad":"bat"}`)
+		case "/boo":
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}
+
+	h1 := httptest.NewServer(http.HandlerFunc(fn))
+	defer h1.Close()
+
+	c1 := cache.NewProvider(newSample(), "zzz")
+
+	t.Run("MaxConcurrency", func(t *testing.T) {
+		atomic.StoreInt32(&peak, 0)
+
+		q1 := cache.NewRemoteProvider(c1, cache.RemoteOption{
+			Resolver:       &resolver{},
+			MaxConcurrency: 2,
+		})
+
+		keys := []string{"zoo", "zoo", "zoo", "zoo", "zoo", "zoo"}
+
+		r, _ := http.NewRequest("GET", h1.URL, nil)
+		mb, _, err := q1.FetchMulti(keys, r)
+
+		assert.Nil(t, err)
+		assert.Len(t, mb, 1)
+		assert.True(t, atomic.LoadInt32(&peak) <= 2)
+	})
+
+	t.Run("Retry", func(t *testing.T) {
+		atomic.StoreInt32(&hits, 0)
+
+		q1 := cache.NewRemoteProvider(c1, cache.RemoteOption{
+			Resolver: &resolver{},
+			Retry: cache.RetryPolicy{
+				MaxAttempt: 3,
+				Backoff:    time.Hour,
+			},
+		})
+
+		r, _ := http.NewRequest("GET", h1.URL, nil)
+		b, n, err := q1.Fetch("bad", r)
+
+		assert.Nil(t, err)
+		assert.Equal(t, []byte(`{"bad":"bat"}`), b)
+		assert.Equal(t, 2, n.Attempts)
+	})
+
+	t.Run("Retry (non retryable status)", func(t *testing.T) {
+		atomic.StoreInt32(&hits, 0)
+
+		q1 := cache.NewRemoteProvider(c1, cache.RemoteOption{
+			Resolver: &resolver{},
+			Retry:    cache.RetryPolicy{MaxAttempt: 3},
+		})
+
+		r, _ := http.NewRequest("GET", h1.URL, nil)
+		_, n, err := q1.Fetch("boo", r)
+
+		assert.NotNil(t, err)
+		assert.Equal(t, http.StatusNotFound, n.StatusCode)
+		assert.Equal(t, 1, n.Attempts)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+	})
+
+	t.Run("Retry (exhausted)", func(t *testing.T) {
+		q1 := cache.NewRemoteProvider(c1, cache.RemoteOption{
+			Resolver:  &resolver{},
+			Transport: &failureTransport{},
+			Retry:     cache.RetryPolicy{MaxAttempt: 3, Backoff: time.Millisecond},
+		})
+
+		r, _ := http.NewRequest("GET", h1.URL, nil)
+		_, n, err := q1.Fetch("zoo", r)
+
+		assert.NotNil(t, err)
+		assert.Nil(t, n)
+	})
+
+	t.Run("Context cancellation", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		q1 := cache.NewRemoteProvider(c1, cache.RemoteOption{
+			Resolver:       &resolver{},
+			MaxConcurrency: 1,
+			Retry:          cache.RetryPolicy{MaxAttempt: 3, Backoff: time.Hour},
+		})
+
+		r, _ := http.NewRequestWithContext(ctx, "GET", h1.URL, nil)
+		mb, _, err := q1.FetchMulti([]string{"zoo", "bad", "boo"}, r)
+
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), context.Canceled.Error())
+		assert.Empty(t, mb)
+	})
+}
+
 type resolver struct{}
 
 func (v *resolver) Resolve(key string, r *http.Request) (*http.Request, error) {
@@ -98,6 +387,8 @@ func (v *resolver) Resolve(key string, r *http.Request) (*http.Request, error) {
 
 	keys := map[string]string{
 		"zzz:bad": "/bad",
+		"zzz:boo": "/boo",
+		"zzz:nob": "/nob",
 		"zzz:zoo": "/zoo",
 	}
 
@@ -128,3 +419,60 @@ type failureTransport struct{}
 func (t *failureTransport) RoundTrip(r *http.Request) (*http.Response, error) {
 	return nil, errors.New("Connection failure")
 }
+
+type entry struct {
+	value string
+	ttl   time.Duration
+}
+
+type memory struct {
+	mu   sync.Mutex
+	data map[string]entry
+}
+
+func newMemory() *memory {
+	return &memory{data: make(map[string]entry)}
+}
+
+func (m *memory) Name() string {
+	return "cache/memory"
+}
+
+func (m *memory) Write(key string, value []byte, expiration time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.data[key] = entry{value: string(value), ttl: expiration}
+	return nil
+}
+
+func (m *memory) Read(key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if v, ok := m.data[key]; ok {
+		return []byte(v.value), nil
+	}
+
+	return nil, errors.New("unknown cache")
+}
+
+func (m *memory) ReadMulti(keys []string) (map[string][]byte, error) {
+	z := make(map[string][]byte, len(keys))
+
+	for _, key := range keys {
+		if v, err := m.Read(key); err == nil {
+			z[key] = v
+		}
+	}
+
+	return z, nil
+}
+
+func (m *memory) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.data, key)
+	return nil
+}
@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// TemplateResolver resolves cache key to http GET request using URL template, e.g. "https://svc/items/{key}".
+// The "{key}" placeholder is replaced with the key without namespace, while "{namespace}" is replaced with the namespace.
+// Placeholders in the path are path escaped, except for "/" separating nested keys, e.g. "foo/bar", while those in
+// the query are query escaped. Keys having "." or ".." path segment are rejected.
+type TemplateResolver struct {
+	// Template is the default URL template.
+	Template string
+
+	// Namespaces maps cache namespace to its own URL template, routing keys to different backends.
+	Namespaces map[string]string
+
+	// Headers lists the inbound request headers copied to the resolved request.
+	Headers []string
+}
+
+// Resolve implements Resolver.
+func (v *TemplateResolver) Resolve(key string, r *http.Request) (*http.Request, error) {
+	ns, id := splitKey(key)
+
+	tpl, ok := v.Namespaces[ns]
+	if !ok {
+		tpl = v.Template
+	}
+
+	if tpl == "" {
+		return nil, errors.Errorf("no URL template for namespace: %q", ns)
+	}
+
+	if hasDotSegment(id) || hasDotSegment(ns) {
+		return nil, errors.Errorf("invalid key: %q", key)
+	}
+
+	s := expand(tpl, ns, id)
+
+	ctx := context.Background()
+	if r != nil {
+		ctx = r.Context()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if r != nil {
+		for _, h := range v.Headers {
+			for _, x := range r.Header.Values(h) {
+				req.Header.Add(h, x)
+			}
+		}
+	}
+
+	return req, nil
+}
+
+func splitKey(key string) (string, string) {
+	if n := strings.SplitN(key, ":", 2); len(n) == 2 {
+		return n[0], n[1]
+	}
+
+	return "", key
+}
+
+// expand replaces the placeholders of tpl, escaping them according to their position in the URL.
+func expand(tpl, ns, id string) string {
+	path, query := tpl, ""
+	if i := strings.IndexAny(tpl, "?#"); i >= 0 {
+		path, query = tpl[:i], tpl[i:]
+	}
+
+	path = strings.NewReplacer("{key}", escapePath(id), "{namespace}", escapePath(ns)).Replace(path)
+	query = strings.NewReplacer("{key}", url.QueryEscape(id), "{namespace}", url.QueryEscape(ns)).Replace(query)
+
+	return path + query
+}
+
+// escapePath escapes each segment of the path, so it can't inject query or fragment to the URL.
+func escapePath(s string) string {
+	ss := strings.Split(s, "/")
+	for i := range ss {
+		ss[i] = url.PathEscape(ss[i])
+	}
+
+	return strings.Join(ss, "/")
+}
+
+func hasDotSegment(s string) bool {
+	for _, x := range strings.Split(s, "/") {
+		if x == "." || x == ".." {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,149 @@
+package cache_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bukalapak/ottoman/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+type ctxKey struct{}
+
+func TestTemplateResolver(t *testing.T) {
+	x := &cache.TemplateResolver{
+		Template: "https://svc.example.com/items/{key}",
+		Namespaces: map[string]string{
+			"usr": "https://user.example.com/{namespace}/{key}?full=1",
+		},
+		Headers: []string{"Authorization", "X-Request-Id"},
+	}
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "foo")
+
+	r, _ := http.NewRequestWithContext(ctx, "POST", "http://example.com/", nil)
+	r.Header.Set("Authorization", "Bearer token")
+	r.Header.Set("Cookie", "secret=1")
+
+	t.Run("Resolve", func(t *testing.T) {
+		req, err := x.Resolve("zzz:foo/bar", r)
+		assert.Nil(t, err)
+		assert.Equal(t, "GET", req.Method)
+		assert.Equal(t, "https://svc.example.com/items/foo/bar", req.URL.String())
+		assert.Equal(t, "Bearer token", req.Header.Get("Authorization"))
+		assert.Empty(t, req.Header.Get("Cookie"))
+		assert.Equal(t, "foo", req.Context().Value(ctxKey{}))
+	})
+
+	t.Run("Resolve (namespace)", func(t *testing.T) {
+		req, err := x.Resolve("usr:42", r)
+		assert.Nil(t, err)
+		assert.Equal(t, "https://user.example.com/usr/42?full=1", req.URL.String())
+	})
+
+	t.Run("Resolve (escape)", func(t *testing.T) {
+		req, err := x.Resolve("zzz:a?admin=1#x", r)
+		assert.Nil(t, err)
+		assert.Equal(t, "/items/a?admin=1#x", req.URL.Path)
+		assert.Empty(t, req.URL.RawQuery)
+		assert.Empty(t, req.URL.Fragment)
+		assert.Equal(t, "https://svc.example.com/items/a%3Fadmin=1%23x", req.URL.String())
+
+		req, err = x.Resolve("usr:100% sure/b c", r)
+		assert.Nil(t, err)
+		assert.Equal(t, "/usr/100% sure/b c", req.URL.Path)
+		assert.Equal(t, "full=1", req.URL.RawQuery)
+		assert.Equal(t, "https://user.example.com/usr/100%25%20sure/b%20c?full=1", req.URL.String())
+	})
+
+	t.Run("Resolve (escape query)", func(t *testing.T) {
+		z := &cache.TemplateResolver{Template: "https://svc.example.com/items?id={key}&ns={namespace}"}
+
+		req, err := z.Resolve("zzz:1&admin=1#x", nil)
+		assert.Nil(t, err)
+		assert.Equal(t, "1&admin=1#x", req.URL.Query().Get("id"))
+		assert.Equal(t, "zzz", req.URL.Query().Get("ns"))
+		assert.Empty(t, req.URL.Query().Get("admin"))
+		assert.Empty(t, req.URL.Fragment)
+	})
+
+	t.Run("Resolve (dot segment)", func(t *testing.T) {
+		for _, key := range []string{"zzz:../../admin", "zzz:foo/./bar", "zzz:..", "..:foo"} {
+			req, err := x.Resolve(key, r)
+			assert.NotNil(t, err, key)
+			assert.Nil(t, req)
+		}
+
+		req, err := x.Resolve("zzz:foo..bar/.baz", r)
+		assert.Nil(t, err)
+		assert.Equal(t, "/items/foo..bar/.baz", req.URL.Path)
+	})
+
+	t.Run("Resolve (no template)", func(t *testing.T) {
+		z := &cache.TemplateResolver{Namespaces: map[string]string{"usr": "http://user.example.com/{key}"}}
+
+		req, err := z.Resolve("zzz:foo", nil)
+		assert.Equal(t, `no URL template for namespace: "zzz"`, err.Error())
+		assert.Nil(t, req)
+
+		req, err = z.Resolve("usr:foo", nil)
+		assert.Nil(t, err)
+		assert.Equal(t, "http://user.example.com/foo", req.URL.String())
+	})
+
+	t.Run("Resolve (invalid URL)", func(t *testing.T) {
+		z := &cache.TemplateResolver{Template: "http://%zz/{key}"}
+
+		_, err := z.Resolve("zzz:foo", nil)
+		assert.NotNil(t, err)
+	})
+}
+
+func TestRemoteProvider_Normalization(t *testing.T) {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.URL.Path)
+	}
+
+	h1 := httptest.NewServer(http.HandlerFunc(fn))
+	defer h1.Close()
+
+	v := &recordResolver{TemplateResolver: cache.TemplateResolver{Template: h1.URL + "/{namespace}/{key}"}}
+	q1 := cache.NewRemoteProvider(cache.NewProvider(newSample(), "zzz"), cache.RemoteOption{Resolver: v})
+
+	t.Run("Fetch", func(t *testing.T) {
+		v.keys = nil
+
+		b, _, err := q1.Fetch("yyy:foo", nil)
+		assert.Nil(t, err)
+		assert.Equal(t, "/zzz/foo", string(b))
+		assert.Equal(t, []string{"zzz:foo"}, v.keys)
+	})
+
+	t.Run("Resolve", func(t *testing.T) {
+		v.keys = nil
+
+		req, err := q1.Resolve("foo", nil)
+		assert.Nil(t, err)
+		assert.Equal(t, "/zzz/foo", req.URL.Path)
+		assert.Equal(t, []string{"zzz:foo"}, v.keys)
+	})
+
+	t.Run("Normalize (idempotent)", func(t *testing.T) {
+		for _, k := range []string{"foo", "yyy:foo", "zzz:foo", "a:b:c"} {
+			assert.Equal(t, q1.Normalize(k), q1.Normalize(q1.Normalize(k)))
+		}
+	})
+}
+
+type recordResolver struct {
+	cache.TemplateResolver
+	keys []string
+}
+
+func (v *recordResolver) Resolve(key string, r *http.Request) (*http.Request, error) {
+	v.keys = append(v.keys, key)
+	return v.TemplateResolver.Resolve(key, r)
+}
@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const defaultRetryBackoff = 100 * time.Millisecond
+
+// RetryPolicy is the configuration for retrying failed remote requests.
+// Requests failing on network error, 5xx or 429 status code are retried with exponential backoff.
+type RetryPolicy struct {
+	// MaxAttempt is the maximum number of attempts, including the first one. Zero or one disables retry.
+	MaxAttempt int
+
+	// Backoff is the base delay between attempts, doubled on every retry. Default to 100ms.
+	Backoff time.Duration
+
+	// MaxBackoff caps the delay between attempts, including the one requested by Retry-After header.
+	// Zero means no limit.
+	MaxBackoff time.Duration
+}
+
+func (n RetryPolicy) maxAttempt() int {
+	if n.MaxAttempt < 1 {
+		return 1
+	}
+
+	return n.MaxAttempt
+}
+
+func (n RetryPolicy) backoff(attempt int, resp *http.Response) time.Duration {
+	d := n.Backoff
+	if d <= 0 {
+		d = defaultRetryBackoff
+	}
+
+	d = d << uint(attempt-1)
+
+	if resp != nil {
+		if v, ok := retryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			d = v
+		}
+	}
+
+	if n.MaxBackoff > 0 && d > n.MaxBackoff {
+		d = n.MaxBackoff
+	}
+
+	return d
+}
+
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+}
+
+func retryAfter(s string, now time.Time) (time.Duration, bool) {
+	if s == "" {
+		return 0, false
+	}
+
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if n < 0 {
+			return 0, false
+		}
+
+		return time.Duration(n) * time.Second, true
+	}
+
+	t, err := http.ParseTime(s)
+	if err != nil {
+		return 0, false
+	}
+
+	if d := t.Sub(now); d > 0 {
+		return d, true
+	}
+
+	return 0, true
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
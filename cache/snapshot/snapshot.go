@@ -0,0 +1,194 @@
+// Package snapshot provides exporting, importing and warming up of cache data.
+package snapshot
+
+import (
+	"io"
+	"time"
+
+	"github.com/bukalapak/ottoman/cache"
+	"github.com/bukalapak/ottoman/encoding/json"
+	"github.com/bukalapak/ottoman/encoding/msgpack"
+	"github.com/pkg/errors"
+)
+
+// Format represents the encoding format of a snapshot file.
+type Format int
+
+const (
+	// JSONLines encodes each entry as a single line of JSON.
+	JSONLines Format = iota
+	// MsgPack encodes entries as a stream of MessagePack values.
+	MsgPack
+)
+
+const (
+	defaultPattern   = "*"
+	defaultBatchSize = 100
+)
+
+// Entry is a single cache item within a snapshot.
+type Entry struct {
+	Key   string        `json:"key" msgpack:"key"`
+	Value []byte        `json:"value" msgpack:"value"`
+	TTL   time.Duration `json:"ttl" msgpack:"ttl"`
+}
+
+// Option is the configuration option for snapshot operations.
+type Option struct {
+	Format Format
+
+	// Pattern filters exported keys, relative to the provider namespace. Default to "*".
+	Pattern string
+
+	// Rate limits the number of cache operations per second. Zero means unlimited.
+	Rate int
+
+	// BatchSize is the number of keys read or fetched at once. Default to 100.
+	BatchSize int
+
+	// Expiration is used when warming up keys. Zero means no expiration.
+	Expiration time.Duration
+}
+
+func (n Option) pattern() string {
+	if n.Pattern == "" {
+		return defaultPattern
+	}
+
+	return n.Pattern
+}
+
+func (n Option) batchSize() int {
+	if n.BatchSize <= 0 {
+		return defaultBatchSize
+	}
+
+	return n.BatchSize
+}
+
+type decoder interface {
+	Decode(v interface{}) error
+}
+
+func newEncoder(w io.Writer, f Format) func(v interface{}) error {
+	if f == MsgPack {
+		c := msgpack.NewEncoder(w)
+		return func(v interface{}) error { return c.Encode(v) }
+	}
+
+	return json.NewEncoder(w).Encode
+}
+
+func newDecoder(r io.Reader, f Format) decoder {
+	if f == MsgPack {
+		return msgpack.NewDecoder(r)
+	}
+
+	return json.NewDecoder(r)
+}
+
+// Export writes the keys within the namespace of p, along with their remaining TTL, to w.
+// The storage behind p must implement cache.Scanner. It returns the number of exported entries.
+func Export(w io.Writer, p cache.Provider, opt Option) (int, error) {
+	s, ok := p.(cache.Scanner)
+	if !ok {
+		return 0, cache.ErrScanUnsupported
+	}
+
+	keys, err := s.Scan(opt.pattern())
+	if err != nil {
+		return 0, err
+	}
+
+	encode := newEncoder(w, opt.Format)
+	lim := newLimiter(opt.Rate)
+	defer lim.Stop()
+
+	n := 0
+	size := opt.batchSize()
+
+	for i := 0; i < len(keys); i += size {
+		ks := keys[i:min(i+size, len(keys))]
+
+		lim.Wait()
+
+		mb, err := p.ReadMulti(ks)
+		if err != nil {
+			return n, err
+		}
+
+		for _, k := range ks {
+			b, ok := mb[k]
+			if !ok || len(b) == 0 {
+				continue
+			}
+
+			d, err := s.TTL(k)
+			if err != nil {
+				continue
+			}
+
+			if err := encode(&Entry{Key: k, Value: b, TTL: d}); err != nil {
+				return n, errors.Wrap(err, k)
+			}
+
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// Import reads snapshot entries from r and writes them into z. It returns the number of imported entries.
+// When z is a cache.Provider, keys are normalized into its namespace.
+func Import(r io.Reader, z cache.Storage, opt Option) (int, error) {
+	dec := newDecoder(r, opt.Format)
+	lim := newLimiter(opt.Rate)
+	defer lim.Stop()
+
+	n := 0
+
+	for {
+		var e Entry
+
+		if err := dec.Decode(&e); err != nil {
+			if err == io.EOF {
+				return n, nil
+			}
+
+			return n, err
+		}
+
+		lim.Wait()
+
+		if err := z.Write(e.Key, e.Value, e.TTL); err != nil {
+			return n, errors.Wrap(err, e.Key)
+		}
+
+		n++
+	}
+}
+
+type limiter struct {
+	ticker *time.Ticker
+}
+
+func newLimiter(rate int) *limiter {
+	if rate <= 0 {
+		return &limiter{}
+	}
+
+	return &limiter{ticker: time.NewTicker(time.Second / time.Duration(rate))}
+}
+
+func (l *limiter) Wait() {
+	if l.ticker != nil {
+		<-l.ticker.C
+	}
+}
+
+func (l *limiter) Stop() {
+	if l.ticker != nil {
+		l.ticker.Stop()
+	}
+}
@@ -0,0 +1,156 @@
+package snapshot_test
+
+import (
+	"bytes"
+	"errors"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bukalapak/ottoman/cache"
+	"github.com/bukalapak/ottoman/cache/snapshot"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportImport(t *testing.T) {
+	formats := map[string]snapshot.Format{
+		"JSONLines": snapshot.JSONLines,
+		"MsgPack":   snapshot.MsgPack,
+	}
+
+	for name, format := range formats {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			p1 := cache.NewProvider(newMemory(), "zzz")
+			n, err := snapshot.Export(&buf, p1, snapshot.Option{Format: format, BatchSize: 1})
+			assert.Nil(t, err)
+			assert.Equal(t, 2, n)
+
+			z2 := newMemory()
+			z2.data = map[string]entry{}
+
+			n, err = snapshot.Import(&buf, z2, snapshot.Option{Format: format, Rate: 1000})
+			assert.Nil(t, err)
+			assert.Equal(t, 2, n)
+			assert.Equal(t, entry{value: `{"zzz":"bar"}`, ttl: time.Minute}, z2.data["zzz:foo"])
+			assert.Equal(t, entry{value: `{"zzz":"baz"}`}, z2.data["zzz:boo"])
+		})
+	}
+
+	t.Run("Export (pattern)", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		p1 := cache.NewProvider(newMemory(), "zzz")
+		n, err := snapshot.Export(&buf, p1, snapshot.Option{Pattern: "f*"})
+		assert.Nil(t, err)
+		assert.Equal(t, 1, n)
+		assert.Contains(t, buf.String(), `"key":"zzz:foo"`)
+	})
+
+	t.Run("Export (unsupported)", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		p1 := cache.NewRemoteProvider(cache.NewProvider(newMemory(), "zzz"), cache.RemoteOption{})
+		_, err := snapshot.Export(&buf, p1, snapshot.Option{})
+		assert.Equal(t, cache.ErrScanUnsupported, err)
+	})
+
+	t.Run("Import (other namespace)", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		p1 := cache.NewProvider(newMemory(), "zzz")
+		snapshot.Export(&buf, p1, snapshot.Option{})
+
+		z2 := newMemory()
+		z2.data = map[string]entry{}
+
+		n, err := snapshot.Import(&buf, cache.NewProvider(z2, "yyy"), snapshot.Option{})
+		assert.Nil(t, err)
+		assert.Equal(t, 2, n)
+		assert.Equal(t, `{"zzz":"bar"}`, z2.data["yyy:foo"].value)
+	})
+
+	t.Run("Import (invalid)", func(t *testing.T) {
+		n, err := snapshot.Import(strings.NewReader("{"), newMemory(), snapshot.Option{})
+		assert.NotNil(t, err)
+		assert.Zero(t, n)
+	})
+}
+
+type entry struct {
+	value string
+	ttl   time.Duration
+}
+
+type memory struct {
+	data map[string]entry
+}
+
+func newMemory() *memory {
+	return &memory{
+		data: map[string]entry{
+			"foo":     {value: `{"foo":"bar"}`},
+			"zzz:foo": {value: `{"zzz":"bar"}`, ttl: time.Minute},
+			"zzz:boo": {value: `{"zzz":"baz"}`},
+		},
+	}
+}
+
+func (m *memory) Name() string {
+	return "cache/memory"
+}
+
+func (m *memory) Write(key string, value []byte, expiration time.Duration) error {
+	m.data[key] = entry{value: string(value), ttl: expiration}
+	return nil
+}
+
+func (m *memory) Read(key string) ([]byte, error) {
+	if v, ok := m.data[key]; ok {
+		return []byte(v.value), nil
+	}
+
+	return nil, errors.New("unknown cache")
+}
+
+func (m *memory) ReadMulti(keys []string) (map[string][]byte, error) {
+	z := make(map[string][]byte, len(keys))
+
+	for _, key := range keys {
+		if v, ok := m.data[key]; ok {
+			z[key] = []byte(v.value)
+		}
+	}
+
+	return z, nil
+}
+
+func (m *memory) Delete(key string) error {
+	delete(m.data, key)
+	return nil
+}
+
+func (m *memory) Scan(pattern string) ([]string, error) {
+	var keys []string
+
+	for k := range m.data {
+		if ok, _ := path.Match(pattern, k); ok {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+func (m *memory) TTL(key string) (time.Duration, error) {
+	if v, ok := m.data[key]; ok {
+		return v.ttl, nil
+	}
+
+	return 0, errors.New("unknown cache")
+}
@@ -0,0 +1,62 @@
+package snapshot
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/bukalapak/ottoman/cache"
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+)
+
+// Warm reads newline separated keys from r, fetches them in batches using p.FetchMulti and writes the results into p.
+// The request r is passed to the resolver as the base for each remote request. It returns the number of warmed keys.
+func Warm(r io.Reader, p cache.RemoteProvider, req *http.Request, opt Option) (int, error) {
+	keys, err := readKeys(r)
+	if err != nil {
+		return 0, err
+	}
+
+	lim := newLimiter(opt.Rate)
+	defer lim.Stop()
+
+	var mrr *multierror.Error
+
+	n := 0
+	size := opt.batchSize()
+
+	for i := 0; i < len(keys); i += size {
+		lim.Wait()
+
+		mb, _, err := p.FetchMulti(keys[i:min(i+size, len(keys))], req)
+		if err != nil {
+			mrr = multierror.Append(mrr, err)
+		}
+
+		for k, b := range mb {
+			if err := p.Write(k, b, opt.Expiration); err != nil {
+				mrr = multierror.Append(mrr, errors.Wrap(err, k))
+				continue
+			}
+
+			n++
+		}
+	}
+
+	return n, mrr.ErrorOrNil()
+}
+
+func readKeys(r io.Reader) ([]string, error) {
+	var keys []string
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		if k := strings.TrimSpace(s.Text()); k != "" {
+			keys = append(keys, k)
+		}
+	}
+
+	return keys, s.Err()
+}
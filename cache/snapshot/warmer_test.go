@@ -0,0 +1,52 @@
+package snapshot_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bukalapak/ottoman/cache"
+	"github.com/bukalapak/ottoman/cache/snapshot"
+	httpclone "github.com/bukalapak/ottoman/http/clone"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWarm(t *testing.T) {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/bad" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		io.WriteString(w, `{"path":"`+r.URL.Path+`"}`)
+	}
+
+	h1 := httptest.NewServer(http.HandlerFunc(fn))
+	defer h1.Close()
+
+	z1 := newMemory()
+	p1 := cache.NewRemoteProvider(cache.NewProvider(z1, "zzz"), cache.RemoteOption{
+		Resolver: &resolver{},
+	})
+
+	r, _ := http.NewRequest("GET", h1.URL, nil)
+	keys := "foo\n\nzzz:bar\nbad\n"
+
+	n, err := snapshot.Warm(strings.NewReader(keys), p1, r, snapshot.Option{BatchSize: 2, Expiration: time.Hour})
+	assert.Contains(t, err.Error(), "zzz:bad")
+	assert.Equal(t, 2, n)
+	assert.Equal(t, entry{value: `{"path":"/foo"}`, ttl: time.Hour}, z1.data["zzz:foo"])
+	assert.Equal(t, entry{value: `{"path":"/bar"}`, ttl: time.Hour}, z1.data["zzz:bar"])
+}
+
+type resolver struct{}
+
+func (v *resolver) Resolve(key string, r *http.Request) (*http.Request, error) {
+	req := httpclone.Request(r)
+	req.URL.Path = "/" + strings.TrimPrefix(key, "zzz:")
+
+	return req, nil
+}
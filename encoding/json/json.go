@@ -48,3 +48,8 @@ func Unmarshal(b []byte, v interface{}) error {
 	c := NewDecoder(bytes.NewReader(b))
 	return c.Decode(v)
 }
+
+// Valid reports whether b is a valid JSON encoding.
+func Valid(b []byte) bool {
+	return json.Valid(b)
+}
@@ -77,3 +77,8 @@ func TestUnmarshal(t *testing.T) {
 	assert.Equal(t, json.Number("0.8"), z["float"])
 	assert.Equal(t, json.Number("10"), z["int"])
 }
+
+func TestValid(t *testing.T) {
+	assert.True(t, jsonx.Valid([]byte(`{"foo":"bar"}`)))
+	assert.False(t, jsonx.Valid([]byte(`<html></html>`)))
+}
@@ -0,0 +1,315 @@
+// Package breaker provides a circuit breaker http.RoundTripper, tracking each upstream host separately.
+package breaker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrOpen is returned when the circuit of the upstream is open and the request is short-circuited.
+// proxy.Proxy responds it with 503 Service Unavailable.
+var ErrOpen = errors.New("breaker: circuit is open")
+
+const (
+	defaultWindow           = 10 * time.Second
+	defaultBuckets          = 10
+	defaultMinRequests      = 20
+	defaultErrorRate        = 0.5
+	defaultOpenTimeout      = 5 * time.Second
+	defaultHalfOpenRequests = 1
+)
+
+// State is the circuit state.
+type State int
+
+const (
+	Closed State = iota
+	Open
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+
+	return "closed"
+}
+
+// Option is the configuration option for the circuit breaker.
+type Option struct {
+	// Window is the rolling window duration for computing error rate. Default to 10s.
+	Window time.Duration
+
+	// Buckets is the number of buckets the window is divided into. Default to 10.
+	Buckets int
+
+	// MinRequests is the minimum number of requests within the window before the circuit may open. Default to 20.
+	MinRequests int
+
+	// ErrorRate is the failure ratio within the window opening the circuit. Default to 0.5.
+	ErrorRate float64
+
+	// OpenTimeout is the duration the circuit stays open before allowing trial requests. Default to 5s.
+	OpenTimeout time.Duration
+
+	// HalfOpenRequests is the number of concurrent trial requests allowed when half-open. Default to 1.
+	HalfOpenRequests int
+
+	// IsFailure reports whether the request failed. Default to network error or 5xx status code.
+	// Canceled requests, e.g. client disconnects or losing hedged requests, are not failures by default.
+	IsFailure func(resp *http.Response, err error) bool
+}
+
+func (n Option) withDefault() Option {
+	if n.Window <= 0 {
+		n.Window = defaultWindow
+	}
+
+	if n.Buckets <= 0 {
+		n.Buckets = defaultBuckets
+	}
+
+	if n.MinRequests <= 0 {
+		n.MinRequests = defaultMinRequests
+	}
+
+	if n.ErrorRate <= 0 {
+		n.ErrorRate = defaultErrorRate
+	}
+
+	if n.OpenTimeout <= 0 {
+		n.OpenTimeout = defaultOpenTimeout
+	}
+
+	if n.HalfOpenRequests <= 0 {
+		n.HalfOpenRequests = defaultHalfOpenRequests
+	}
+
+	if n.IsFailure == nil {
+		n.IsFailure = isFailure
+	}
+
+	return n
+}
+
+func isFailure(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled)
+	}
+
+	return resp.StatusCode >= http.StatusInternalServerError
+}
+
+// Transport is an http.RoundTripper guarding each upstream host with its own circuit breaker.
+// It can be used as RoundTripper of proxy.Transformer or cache.RemoteOption.Transport.
+type Transport struct {
+	next     http.RoundTripper
+	option   Option
+	mu       sync.Mutex
+	breakers map[string]*Breaker
+}
+
+// New returns a Transport wrapping next. A nil next uses http.DefaultTransport.
+func New(next http.RoundTripper, opt Option) *Transport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &Transport{
+		next:     next,
+		option:   opt.withDefault(),
+		breakers: make(map[string]*Breaker),
+	}
+}
+
+// RoundTrip implements http.RoundTripper. It returns ErrOpen without sending the request when the circuit is open.
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	b := t.Breaker(r.URL.Host)
+
+	if err := b.Allow(); err != nil {
+		if r.Body != nil {
+			r.Body.Close()
+		}
+
+		return nil, err
+	}
+
+	resp, err := t.next.RoundTrip(r)
+	b.Record(!t.option.IsFailure(resp, err))
+
+	return resp, err
+}
+
+// Breaker returns the circuit breaker of the upstream host.
+func (t *Transport) Breaker(host string) *Breaker {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b, ok := t.breakers[host]
+	if !ok {
+		b = newBreaker(t.option)
+		t.breakers[host] = b
+	}
+
+	return b
+}
+
+// State returns the circuit state of the upstream host.
+func (t *Transport) State(host string) State {
+	return t.Breaker(host).State()
+}
+
+// Breaker is a circuit breaker with rolling error rate window.
+type Breaker struct {
+	mu       sync.Mutex
+	option   Option
+	state    State
+	openedAt time.Time
+	trials   int
+	buckets  []bucket
+}
+
+type bucket struct {
+	start     time.Time
+	successes int
+	failures  int
+}
+
+// NewBreaker returns a standalone circuit breaker.
+func NewBreaker(opt Option) *Breaker {
+	return newBreaker(opt.withDefault())
+}
+
+func newBreaker(opt Option) *Breaker {
+	return &Breaker{
+		option:  opt,
+		buckets: make([]bucket, opt.Buckets),
+	}
+}
+
+// State returns the current circuit state.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.transition(time.Now())
+
+	return b.state
+}
+
+// Allow reports whether a request may proceed. It returns ErrOpen when the request is short-circuited.
+// Every allowed request must be followed by Record.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.transition(time.Now())
+
+	switch b.state {
+	case Open:
+		return ErrOpen
+	case HalfOpen:
+		if b.trials >= b.option.HalfOpenRequests {
+			return ErrOpen
+		}
+
+		b.trials++
+	}
+
+	return nil
+}
+
+// Record records the result of an allowed request.
+func (b *Breaker) Record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+
+	switch b.state {
+	case HalfOpen:
+		if b.trials > 0 {
+			b.trials--
+		}
+
+		if success {
+			b.reset(Closed)
+		} else {
+			b.open(now)
+		}
+
+		return
+	case Open:
+		return
+	}
+
+	k := b.bucket(now)
+
+	if success {
+		k.successes++
+	} else {
+		k.failures++
+	}
+
+	if b.tripped(now) {
+		b.open(now)
+	}
+}
+
+func (b *Breaker) transition(now time.Time) {
+	if b.state == Open && now.Sub(b.openedAt) >= b.option.OpenTimeout {
+		b.state = HalfOpen
+		b.trials = 0
+	}
+}
+
+func (b *Breaker) open(now time.Time) {
+	b.reset(Open)
+	b.openedAt = now
+}
+
+func (b *Breaker) reset(s State) {
+	b.state = s
+	b.trials = 0
+
+	for i := range b.buckets {
+		b.buckets[i] = bucket{}
+	}
+}
+
+func (b *Breaker) width() time.Duration {
+	return b.option.Window / time.Duration(b.option.Buckets)
+}
+
+func (b *Breaker) bucket(now time.Time) *bucket {
+	start := now.Truncate(b.width())
+	k := &b.buckets[int(start.UnixNano()/int64(b.width()))%len(b.buckets)]
+
+	if !k.start.Equal(start) {
+		*k = bucket{start: start}
+	}
+
+	return k
+}
+
+func (b *Breaker) tripped(now time.Time) bool {
+	var successes, failures int
+
+	for _, k := range b.buckets {
+		if now.Sub(k.start) < b.option.Window {
+			successes += k.successes
+			failures += k.failures
+		}
+	}
+
+	total := successes + failures
+
+	return total >= b.option.MinRequests && float64(failures)/float64(total) >= b.option.ErrorRate
+}
@@ -0,0 +1,172 @@
+package breaker_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bukalapak/ottoman/cache"
+	"github.com/bukalapak/ottoman/http/breaker"
+	"github.com/bukalapak/ottoman/proxy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBreaker(t *testing.T) {
+	b := breaker.NewBreaker(breaker.Option{
+		MinRequests: 4,
+		ErrorRate:   0.5,
+		OpenTimeout: 20 * time.Millisecond,
+	})
+
+	record := func(ok bool) {
+		assert.Nil(t, b.Allow())
+		b.Record(ok)
+	}
+
+	record(true)
+	record(false)
+	record(true)
+	assert.Equal(t, breaker.Closed, b.State())
+
+	record(false)
+	assert.Equal(t, breaker.Open, b.State())
+	assert.Equal(t, breaker.ErrOpen, b.Allow())
+
+	time.Sleep(25 * time.Millisecond)
+	assert.Equal(t, breaker.HalfOpen, b.State())
+
+	assert.Nil(t, b.Allow())
+	assert.Equal(t, breaker.ErrOpen, b.Allow())
+
+	b.Record(false)
+	assert.Equal(t, breaker.Open, b.State())
+
+	time.Sleep(25 * time.Millisecond)
+
+	assert.Nil(t, b.Allow())
+	b.Record(true)
+	assert.Equal(t, breaker.Closed, b.State())
+	assert.Equal(t, "closed", b.State().String())
+}
+
+func TestBreaker_Window(t *testing.T) {
+	b := breaker.NewBreaker(breaker.Option{
+		Window:      40 * time.Millisecond,
+		Buckets:     4,
+		MinRequests: 2,
+	})
+
+	b.Allow()
+	b.Record(false)
+
+	time.Sleep(50 * time.Millisecond)
+
+	b.Allow()
+	b.Record(true)
+	b.Allow()
+	b.Record(false)
+
+	assert.Equal(t, breaker.Open, b.State())
+	assert.Equal(t, "open", b.State().String())
+}
+
+func TestTransport(t *testing.T) {
+	var hits int32
+
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer bad.Close()
+
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer good.Close()
+
+	x := breaker.New(nil, breaker.Option{MinRequests: 2, OpenTimeout: time.Hour})
+
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest("GET", bad.URL, nil)
+		resp, err := x.RoundTrip(req)
+
+		if i < 2 {
+			assert.Nil(t, err)
+			assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+		} else {
+			assert.Equal(t, breaker.ErrOpen, err)
+		}
+	}
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+
+	ub, _ := url.Parse(bad.URL)
+	ug, _ := url.Parse(good.URL)
+
+	assert.Equal(t, breaker.Open, x.State(ub.Host))
+	assert.Equal(t, breaker.Closed, x.State(ug.Host))
+
+	t.Run("Proxy", func(t *testing.T) {
+		p := proxy.NewProxy(proxy.NewTarget(ub))
+
+		req, _ := http.NewRequest("GET", "/", nil)
+		rec := httptest.NewRecorder()
+
+		p.Forward(rec, req, transform{RoundTripper: x})
+
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+	})
+
+	t.Run("RemoteProvider", func(t *testing.T) {
+		q1 := cache.NewRemoteProvider(cache.NewProvider(nil, "zzz"), cache.RemoteOption{
+			Transport: x,
+			Resolver:  &cache.TemplateResolver{Template: bad.URL + "/{key}"},
+		})
+
+		_, _, err := q1.Fetch("foo", nil)
+
+		assert.True(t, errors.Is(err, breaker.ErrOpen))
+		assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+	})
+}
+
+func TestTransport_canceled(t *testing.T) {
+	x := breaker.New(roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return nil, context.Canceled
+	}), breaker.Option{MinRequests: 2, OpenTimeout: time.Hour})
+
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest("GET", "http://example.com/", nil)
+		_, err := x.RoundTrip(req)
+
+		assert.Equal(t, context.Canceled, err)
+	}
+
+	assert.Equal(t, breaker.Closed, x.State("example.com"))
+}
+
+type roundTripFunc func(r *http.Request) (*http.Response, error)
+
+func (fn roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return fn(r)
+}
+
+type transform struct {
+	http.RoundTripper
+}
+
+func (s transform) Director(t proxy.Targeter) func(r *http.Request) {
+	return func(r *http.Request) {
+		u := t.Target()
+		r.URL.Scheme = u.Scheme
+		r.URL.Host = u.Host
+	}
+}
+
+func (s transform) ModifyResponse(resp *http.Response) error {
+	return nil
+}
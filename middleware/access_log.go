@@ -0,0 +1,221 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bukalapak/ottoman/encoding/json"
+	xhttp "github.com/bukalapak/ottoman/x/http"
+)
+
+const (
+	clfTimeFormat = "02/Jan/2006:15:04:05 -0700"
+	redacted      = "[REDACTED]"
+)
+
+// LogFormat is the format of access log records.
+type LogFormat int
+
+const (
+	// JSONFormat writes AccessRecord as JSON lines.
+	JSONFormat LogFormat = iota
+
+	// CommonFormat writes NCSA Common Log Format lines.
+	CommonFormat
+
+	// CombinedFormat writes NCSA Combined Log Format lines, including referer and user agent.
+	CombinedFormat
+)
+
+var defaultRedact = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+// AccessRecord is the access log record of a request.
+type AccessRecord struct {
+	Time      time.Time         `json:"time"`
+	RequestID string            `json:"request_id,omitempty"`
+	RemoteIP  string            `json:"remote_ip,omitempty"`
+	Method    string            `json:"method"`
+	Path      string            `json:"path"`
+	Query     string            `json:"query,omitempty"`
+	Proto     string            `json:"proto"`
+	Status    int               `json:"status"`
+	Size      int               `json:"size"`
+	LatencyMS float64           `json:"latency_ms"`
+	UserAgent string            `json:"user_agent,omitempty"`
+	Referer   string            `json:"referer,omitempty"`
+	Header    map[string]string `json:"header,omitempty"`
+}
+
+// AccessLog logs every request once the response is written. Place it after RequestID and RealIP in Chain
+// to include the request ID and IP.
+type AccessLog struct {
+	Format LogFormat
+
+	// SampleRate is the fraction of requests logged, from 0 to 1. Zero means all requests.
+	// Requests with 5xx status code are always logged.
+	SampleRate float64
+
+	// Exclude skips requests whose path matches any of patterns, using path.Match syntax, e.g. "/healthz".
+	Exclude []string
+
+	// Headers are the request headers included in JSON records. "*" includes all headers.
+	Headers []string
+
+	// Redact are the headers whose value is replaced in JSON records.
+	// Default to Authorization, Proxy-Authorization and Cookie.
+	Redact []string
+
+	logger *log.Logger
+}
+
+// NewAccessLog returns AccessLog writing JSON records to w.
+func NewAccessLog(w io.Writer) *AccessLog {
+	return &AccessLog{logger: log.New(w, "", 0)}
+}
+
+func (a *AccessLog) Handler(h http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if matchPath(r.URL.Path, a.Exclude) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		now := time.Now()
+		rw := xhttp.NewResponseWriter(w)
+
+		h.ServeHTTP(rw, r)
+
+		status := rw.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		if !a.sampled(status) {
+			return
+		}
+
+		a.logger.Print(a.format(a.record(r, status, rw.Size(), now)))
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+func (a *AccessLog) sampled(status int) bool {
+	if a.SampleRate <= 0 || a.SampleRate >= 1 || status >= http.StatusInternalServerError {
+		return true
+	}
+
+	return rand.Float64() < a.SampleRate
+}
+
+func (a *AccessLog) record(r *http.Request, status, size int, now time.Time) *AccessRecord {
+	x := &AccessRecord{
+		Time:      now,
+		RequestID: RequestIDFromContext(r.Context()),
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		Query:     r.URL.RawQuery,
+		Proto:     r.Proto,
+		Status:    status,
+		Size:      size,
+		LatencyMS: float64(time.Since(now)) / float64(time.Millisecond),
+		UserAgent: r.UserAgent(),
+		Referer:   r.Referer(),
+		Header:    a.header(r.Header),
+	}
+
+	if ip, ok := IPFromContext(r.Context()); ok {
+		x.RemoteIP = ip
+	} else {
+		x.RemoteIP = remoteIP(r)
+	}
+
+	return x
+}
+
+func (a *AccessLog) header(h http.Header) map[string]string {
+	if len(a.Headers) == 0 {
+		return nil
+	}
+
+	ks := a.Headers
+	if len(ks) == 1 && ks[0] == "*" {
+		ks = make([]string, 0, len(h))
+		for k := range h {
+			ks = append(ks, k)
+		}
+	}
+
+	redact := a.Redact
+	if redact == nil {
+		redact = defaultRedact
+	}
+
+	m := make(map[string]string, len(ks))
+
+	for _, k := range ks {
+		k = http.CanonicalHeaderKey(k)
+
+		vs := h.Values(k)
+		if len(vs) == 0 {
+			continue
+		}
+
+		m[k] = strings.Join(vs, ", ")
+
+		for _, s := range redact {
+			if strings.EqualFold(s, k) {
+				m[k] = redacted
+			}
+		}
+	}
+
+	return m
+}
+
+func (a *AccessLog) format(x *AccessRecord) string {
+	if a.Format == JSONFormat {
+		b, err := json.Marshal(x)
+		if err != nil {
+			return err.Error()
+		}
+
+		return strings.TrimSuffix(string(b), "\n")
+	}
+
+	uri := x.Path
+	if x.Query != "" {
+		uri += "?" + x.Query
+	}
+
+	s := fmt.Sprintf("%s - - [%s] \"%s %s %s\" %d %s",
+		clfValue(x.RemoteIP), x.Time.Format(clfTimeFormat), x.Method, uri, x.Proto, x.Status, clfSize(x.Size))
+
+	if a.Format == CombinedFormat {
+		s += fmt.Sprintf(" %q %q", clfValue(x.Referer), clfValue(x.UserAgent))
+	}
+
+	return s
+}
+
+func clfValue(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
+
+func clfSize(n int) string {
+	if n == 0 {
+		return "-"
+	}
+
+	return strconv.Itoa(n)
+}
@@ -0,0 +1,102 @@
+package middleware_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/bukalapak/ottoman/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessLog(t *testing.T) {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/fail":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/empty":
+		default:
+			io.WriteString(w, "hello")
+		}
+	}
+
+	serve := func(a *middleware.AccessLog, req *http.Request) {
+		middleware.NewChain(middleware.RequestID, middleware.RealIP, a.Handler).ThenFunc(fn).ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	t.Run("JSON", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		a := middleware.NewAccessLog(&buf)
+		a.Headers = []string{"*"}
+
+		req := httptest.NewRequest("GET", "/foo?bar=1", nil)
+		req.Header.Set("X-Request-Id", "abc")
+		req.Header.Set("X-Forwarded-For", "10.0.0.1")
+		req.Header.Set("User-Agent", "test")
+		req.Header.Set("Authorization", "Bearer secret")
+
+		serve(a, req)
+
+		var v middleware.AccessRecord
+
+		assert.Nil(t, json.Unmarshal(buf.Bytes(), &v))
+		assert.Equal(t, "abc", v.RequestID)
+		assert.Equal(t, "10.0.0.1", v.RemoteIP)
+		assert.Equal(t, "GET", v.Method)
+		assert.Equal(t, "/foo", v.Path)
+		assert.Equal(t, "bar=1", v.Query)
+		assert.Equal(t, http.StatusOK, v.Status)
+		assert.Equal(t, 5, v.Size)
+		assert.Equal(t, "test", v.UserAgent)
+		assert.Equal(t, "[REDACTED]", v.Header["Authorization"])
+		assert.Equal(t, "test", v.Header["User-Agent"])
+		assert.NotContains(t, buf.String(), "secret")
+	})
+
+	t.Run("Common", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		a := middleware.NewAccessLog(&buf)
+		a.Format = middleware.CommonFormat
+
+		serve(a, httptest.NewRequest("GET", "/empty", nil))
+
+		re := regexp.MustCompile(`^192\.0\.2\.1 - - \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "GET /empty HTTP/1\.1" 200 -\n$`)
+		assert.Regexp(t, re, buf.String())
+	})
+
+	t.Run("Combined", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		a := middleware.NewAccessLog(&buf)
+		a.Format = middleware.CombinedFormat
+
+		req := httptest.NewRequest("GET", "/foo", nil)
+		req.Header.Set("User-Agent", "test")
+
+		serve(a, req)
+
+		assert.True(t, strings.HasSuffix(buf.String(), `"GET /foo HTTP/1.1" 200 5 "-" "test"`+"\n"), buf.String())
+	})
+
+	t.Run("Exclude and sampling", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		a := middleware.NewAccessLog(&buf)
+		a.Exclude = []string{"/healthz"}
+		a.SampleRate = 0.000001
+
+		serve(a, httptest.NewRequest("GET", "/healthz", nil))
+		serve(a, httptest.NewRequest("GET", "/foo", nil))
+		assert.Empty(t, buf.String())
+
+		serve(a, httptest.NewRequest("GET", "/fail", nil))
+		assert.Contains(t, buf.String(), `"status":500`)
+	})
+}
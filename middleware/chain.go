@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"net/http"
+	"path"
+)
+
+// Middleware wraps http.Handler with additional behavior, e.g. RealIP and RequestID.
+type Middleware func(http.Handler) http.Handler
+
+// Chain is an immutable list of middlewares. The first middleware is the outermost one, receiving the request first.
+type Chain struct {
+	ms []Middleware
+}
+
+// NewChain returns Chain of middlewares ms.
+func NewChain(ms ...Middleware) Chain {
+	return Chain{ms: append([]Middleware(nil), ms...)}
+}
+
+// Default returns the default middleware stack: RequestID, TrustedRealIP of ip, then Recovery of v.
+// A nil ip trusts no proxy, so the client IP is always the peer address.
+// Recovery is placed after RequestID and TrustedRealIP, which never panic, so it can report the request ID and IP.
+func Default(ip *TrustedRealIP, v *Recovery) Chain {
+	if ip == nil {
+		ip = &TrustedRealIP{}
+	}
+
+	return NewChain(RequestID, ip.Handler, v.Handler)
+}
+
+// Append returns a new Chain with ms appended.
+func (c Chain) Append(ms ...Middleware) Chain {
+	z := make([]Middleware, 0, len(c.ms)+len(ms))
+	z = append(z, c.ms...)
+	z = append(z, ms...)
+
+	return Chain{ms: z}
+}
+
+// Extend returns a new Chain with middlewares of x appended.
+func (c Chain) Extend(x Chain) Chain {
+	return c.Append(x.ms...)
+}
+
+// Then wraps h with the middlewares. Nil h means http.DefaultServeMux.
+func (c Chain) Then(h http.Handler) http.Handler {
+	if h == nil {
+		h = http.DefaultServeMux
+	}
+
+	for i := len(c.ms) - 1; i >= 0; i-- {
+		h = c.ms[i](h)
+	}
+
+	return h
+}
+
+// ThenFunc wraps fn with the middlewares.
+func (c Chain) ThenFunc(fn http.HandlerFunc) http.Handler {
+	if fn == nil {
+		return c.Then(nil)
+	}
+
+	return c.Then(fn)
+}
+
+// Unless returns Middleware applying m, except for requests satisfying skip.
+func Unless(m Middleware, skip func(r *http.Request) bool) Middleware {
+	return func(h http.Handler) http.Handler {
+		x := m(h)
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if skip(r) {
+				h.ServeHTTP(w, r)
+			} else {
+				x.ServeHTTP(w, r)
+			}
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// SkipPath returns Middleware applying m, except for requests whose path matches any of patterns,
+// using path.Match syntax, e.g. "/healthz" or "/static/*".
+func SkipPath(m Middleware, patterns ...string) Middleware {
+	return Unless(m, func(r *http.Request) bool {
+		return matchPath(r.URL.Path, patterns)
+	})
+}
+
+func matchPath(p string, patterns []string) bool {
+	for _, s := range patterns {
+		if ok, _ := path.Match(s, p); ok {
+			return true
+		}
+	}
+
+	return false
+}
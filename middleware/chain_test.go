@@ -0,0 +1,82 @@
+package middleware_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bukalapak/ottoman/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+func tag(s string) middleware.Middleware {
+	return func(h http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, s)
+			h.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+func TestChain(t *testing.T) {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "!")
+	}
+
+	serve := func(h http.Handler, path string) string {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+
+		return rec.Body.String()
+	}
+
+	c1 := middleware.NewChain(tag("a"), tag("b"))
+	c2 := c1.Append(tag("c"))
+	c3 := c1.Extend(middleware.NewChain(tag("d"), tag("e")))
+
+	assert.Equal(t, "ab!", serve(c1.ThenFunc(fn), "/"))
+	assert.Equal(t, "abc!", serve(c2.ThenFunc(fn), "/"))
+	assert.Equal(t, "abde!", serve(c3.ThenFunc(fn), "/"))
+
+	c4 := middleware.NewChain(tag("a"), middleware.SkipPath(tag("b"), "/healthz", "/static/*"))
+
+	assert.Equal(t, "ab!", serve(c4.ThenFunc(fn), "/users"))
+	assert.Equal(t, "a!", serve(c4.ThenFunc(fn), "/healthz"))
+	assert.Equal(t, "a!", serve(c4.ThenFunc(fn), "/static/app.js"))
+	assert.Equal(t, "ab!", serve(c4.ThenFunc(fn), "/static/js/app.js"))
+
+	c5 := middleware.NewChain(middleware.Unless(tag("a"), func(r *http.Request) bool { return r.Method == "GET" }))
+	assert.Equal(t, "!", serve(c5.ThenFunc(fn), "/"))
+}
+
+func TestDefault(t *testing.T) {
+	var id, ip string
+
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		id = middleware.RequestIDFromContext(r.Context())
+		ip, _ = middleware.IPFromContext(r.Context())
+
+		panic("!!!")
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Request-Id", "abc")
+
+	req.Header.Set("X-Forwarded-For", "10.0.0.1")
+
+	middleware.Default(nil, middleware.NewRecovery(NewAgent(t))).ThenFunc(fn).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, "abc", id)
+	assert.Equal(t, "192.0.2.1", ip)
+
+	x, err := middleware.NewTrustedRealIP("192.0.2.0/24")
+	assert.Nil(t, err)
+
+	middleware.Default(x, middleware.NewRecovery(NewAgent(t))).ThenFunc(fn).ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "10.0.0.1", ip)
+}
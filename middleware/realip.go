@@ -13,6 +13,8 @@ var (
 	contextKeyIP  = ContextKey("RealIP")
 )
 
+// RealIP trusts forwarding headers from any client, so the IP can be spoofed. Use TrustedRealIP when the IP is used
+// for access control. The parsed Addr is not stored, see AddrFromContext.
 func RealIP(h http.Handler) http.Handler {
 	fn := func(w http.ResponseWriter, r *http.Request) {
 		ctx := NewIPContext(r.Context(), realIP(r))
@@ -51,6 +51,16 @@ func TestRealIP(t *testing.T) {
 		x(req, sampleIP.String())
 	})
 
+	t.Run("Addr", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Add("X-Forwarded-For", sampleIP.String())
+
+		middleware.RealIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, ok := middleware.AddrFromContext(r.Context())
+			assert.False(t, ok)
+		})).ServeHTTP(httptest.NewRecorder(), req)
+	})
+
 	t.Run("RemoteAddr", func(t *testing.T) {
 		req := httptest.NewRequest("GET", "/", nil)
 		host, _, _ := net.SplitHostPort(req.RemoteAddr)
@@ -1,41 +1,115 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"runtime/debug"
 
 	"github.com/bukalapak/ottoman/notify"
+	xhttp "github.com/bukalapak/ottoman/x/http"
 )
 
 type RecoveryLogger interface {
 	Error(err interface{}, stackTrace []byte)
 }
 
+// RecoveryMetadataLogger is a RecoveryLogger accepting request metadata.
+type RecoveryMetadataLogger interface {
+	RecoveryLogger
+	ErrorMetadata(err interface{}, stackTrace []byte, md notify.Metadata)
+}
+
 type Recovery struct {
 	Logger RecoveryLogger
-	agent  notify.Notifier
+
+	// Render writes the response of a recovered request, unless the response is already written.
+	// Default to an empty 500 response.
+	Render func(w http.ResponseWriter, r *http.Request, err interface{})
+
+	agent notify.Notifier
 }
 
 func NewRecovery(agent notify.Notifier) *Recovery {
 	return &Recovery{agent: agent, Logger: &nopLogger{}}
 }
 
+// Handler recovers panics, reporting them along with request metadata to the logger and notifier.
+// http.ErrAbortHandler is not recovered, so the server aborts the response silently.
 func (v *Recovery) Handler(h http.Handler) http.Handler {
 	fn := func(w http.ResponseWriter, r *http.Request) {
+		rw := xhttp.NewResponseWriter(w)
+
 		defer func() {
-			if rec := recover(); rec != nil {
-				v.Logger.Error(rec, debug.Stack())
-				v.agent.Notify(rec, debug.Stack())
-				w.WriteHeader(http.StatusInternalServerError)
+			rec := recover()
+			if rec == nil {
+				return
+			}
+
+			if err, ok := rec.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(rec)
+			}
+
+			stack := debug.Stack()
+			md := requestMetadata(r)
+
+			v.log(rec, stack, md)
+			v.notify(rec, stack, md)
+
+			if !rw.Written() {
+				v.render(rw, r, rec)
 			}
 		}()
 
-		h.ServeHTTP(w, r)
+		h.ServeHTTP(rw, r)
 	}
 
 	return http.HandlerFunc(fn)
 }
 
+func (v *Recovery) log(err interface{}, stack []byte, md notify.Metadata) {
+	if l, ok := v.Logger.(RecoveryMetadataLogger); ok {
+		l.ErrorMetadata(err, stack, md)
+	} else {
+		v.Logger.Error(err, stack)
+	}
+}
+
+func (v *Recovery) notify(err interface{}, stack []byte, md notify.Metadata) {
+	if n, ok := v.agent.(notify.MetadataNotifier); ok {
+		n.NotifyMetadata(err, stack, md)
+	} else {
+		v.agent.Notify(err, stack)
+	}
+}
+
+func (v *Recovery) render(w http.ResponseWriter, r *http.Request, err interface{}) {
+	if v.Render != nil {
+		v.Render(w, r, err)
+	} else {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func requestMetadata(r *http.Request) notify.Metadata {
+	md := notify.Metadata{
+		"method":     r.Method,
+		"url":        r.URL.String(),
+		"user_agent": r.UserAgent(),
+	}
+
+	if id := RequestIDFromContext(r.Context()); id != "" {
+		md["request_id"] = id
+	}
+
+	if ip, ok := IPFromContext(r.Context()); ok {
+		md["remote_ip"] = ip
+	} else {
+		md["remote_ip"] = remoteIP(r)
+	}
+
+	return md
+}
+
 type nopLogger struct{}
 
 func (n *nopLogger) Error(err interface{}, stackTrace []byte) {}
@@ -1,11 +1,14 @@
 package middleware_test
 
 import (
+	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/bukalapak/ottoman/middleware"
+	"github.com/bukalapak/ottoman/notify"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -24,6 +27,113 @@ func TestRecovery(t *testing.T) {
 	cov2.Logger = NewLogger(t)
 
 	cov2.Handler(http.HandlerFunc(fn)).ServeHTTP(rec2, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rec1.Code)
+	assert.Equal(t, http.StatusInternalServerError, rec2.Code)
+}
+
+func TestRecovery_Metadata(t *testing.T) {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		panic("!!!")
+	}
+
+	agent := &MetadataAgent{}
+	logger := &MetadataLogger{}
+
+	cov := middleware.NewRecovery(agent)
+	cov.Logger = logger
+	cov.Render = func(w http.ResponseWriter, r *http.Request, err interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{"error": err})
+	}
+
+	req := httptest.NewRequest("POST", "/foo?bar=1", nil)
+	req.Header.Set("X-Request-Id", "abc")
+	req.Header.Set("X-Forwarded-For", "10.0.0.1")
+
+	rec := httptest.NewRecorder()
+	x, _ := middleware.NewTrustedRealIP("192.0.2.0/24")
+	middleware.Default(x, cov).ThenFunc(fn).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.JSONEq(t, `{"error":"!!!"}`, rec.Body.String())
+
+	md := notify.Metadata{
+		"method":     "POST",
+		"url":        "/foo?bar=1",
+		"user_agent": "",
+		"request_id": "abc",
+		"remote_ip":  "10.0.0.1",
+	}
+
+	assert.Equal(t, "!!!", agent.err)
+	assert.NotEmpty(t, agent.stack)
+	assert.Equal(t, md, agent.md)
+	assert.Equal(t, md, logger.md)
+}
+
+func TestRecovery_Written(t *testing.T) {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		io.WriteString(w, "partial")
+		panic("!!!")
+	}
+
+	rendered := false
+
+	cov := middleware.NewRecovery(NewAgent(t))
+	cov.Render = func(w http.ResponseWriter, r *http.Request, err interface{}) {
+		rendered = true
+	}
+
+	rec := httptest.NewRecorder()
+	cov.Handler(http.HandlerFunc(fn)).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Equal(t, "partial", rec.Body.String())
+	assert.False(t, rendered)
+}
+
+func TestRecovery_ErrAbortHandler(t *testing.T) {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}
+
+	agent := &MetadataAgent{}
+	h := middleware.NewRecovery(agent).Handler(http.HandlerFunc(fn))
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	})
+
+	assert.Nil(t, agent.err)
+}
+
+type MetadataAgent struct {
+	err   interface{}
+	stack []byte
+	md    notify.Metadata
+}
+
+func (a *MetadataAgent) Notify(err interface{}, stack []byte) {
+	panic("NotifyMetadata should be called")
+}
+
+func (a *MetadataAgent) NotifyMetadata(err interface{}, stack []byte, md notify.Metadata) {
+	a.err, a.stack, a.md = err, stack, md
+}
+
+type MetadataLogger struct {
+	md notify.Metadata
+}
+
+func (l *MetadataLogger) Error(err interface{}, stack []byte) {
+	panic("ErrorMetadata should be called")
+}
+
+func (l *MetadataLogger) ErrorMetadata(err interface{}, stack []byte, md notify.Metadata) {
+	l.md = md
 }
 
 type Agent struct {
@@ -2,23 +2,70 @@ package middleware
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/binary"
 	"net/http"
 	"regexp"
+	"time"
 
 	uuid "github.com/kevinburke/go.uuid"
 )
 
+const (
+	requestIDHeader    = "X-Request-Id"
+	maxRequestIDLength = 64
+	crockford          = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+)
+
 var (
 	contextKeyRequestID = ContextKey("RequestID")
+	invalidIDChars      = regexp.MustCompile(`[^\w+\-]`)
 )
 
-func RequestID(h http.Handler) http.Handler {
-	fn := func(w http.ResponseWriter, r *http.Request) {
-		ctx := NewRequestIDContext(r.Context(), reqID(r))
-		h.ServeHTTP(w, r.WithContext(ctx))
+// RequestIDOption is the configuration of request ID middleware.
+type RequestIDOption struct {
+	// Header is the request and response header carrying the ID. Default to X-Request-Id.
+	Header string
+
+	// Generator generates the ID of requests without one. Default to UUIDv4.
+	Generator func() string
+}
+
+func (n RequestIDOption) header() string {
+	if n.Header == "" {
+		return requestIDHeader
 	}
 
-	return http.HandlerFunc(fn)
+	return n.Header
+}
+
+func (n RequestIDOption) generate() string {
+	if n.Generator == nil {
+		return UUIDv4()
+	}
+
+	return n.Generator()
+}
+
+// RequestID stores the incoming X-Request-Id, or a generated UUIDv4, in the request context and echoes it in
+// the response header.
+func RequestID(h http.Handler) http.Handler {
+	return NewRequestID(RequestIDOption{})(h)
+}
+
+// NewRequestID returns RequestID middleware using the option.
+func NewRequestID(opt RequestIDOption) Middleware {
+	return func(h http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			id := reqID(r, opt)
+			w.Header().Set(opt.header(), id)
+
+			ctx := NewRequestIDContext(r.Context(), id)
+			h.ServeHTTP(w, r.WithContext(ctx))
+		}
+
+		return http.HandlerFunc(fn)
+	}
 }
 
 func NewRequestIDContext(ctx context.Context, id string) context.Context {
@@ -30,19 +77,101 @@ func RequestIDFromContext(ctx context.Context) string {
 	return id
 }
 
-func reqID(r *http.Request) string {
-	if id := r.Header.Get("X-Request-Id"); id != "" {
-		return cleanID(id)
+// RequestIDTransport is http.RoundTripper setting the request ID from the request context on outgoing requests,
+// e.g. as cache.RemoteOption Transport or proxy.Transformer RoundTripper.
+type RequestIDTransport struct {
+	// Base sends the requests. Default to http.DefaultTransport.
+	Base http.RoundTripper
+
+	// Header is the request header carrying the ID. Default to X-Request-Id.
+	Header string
+}
+
+func (t *RequestIDTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	id := RequestIDFromContext(r.Context())
+	if id == "" {
+		return base.RoundTrip(r)
+	}
+
+	header := t.Header
+	if header == "" {
+		header = requestIDHeader
+	}
+
+	req := r.Clone(r.Context())
+	req.Header.Set(header, id)
+
+	return base.RoundTrip(req)
+}
+
+func reqID(r *http.Request, opt RequestIDOption) string {
+	if id := cleanID(r.Header.Get(opt.header())); id != "" {
+		return id
 	}
 
-	return genID()
+	return opt.generate()
 }
 
-func genID() string {
+// cleanID removes invalid characters and caps the length of incoming ID.
+func cleanID(s string) string {
+	s = invalidIDChars.ReplaceAllLiteralString(s, "")
+
+	if len(s) > maxRequestIDLength {
+		s = s[:maxRequestIDLength]
+	}
+
+	return s
+}
+
+// UUIDv4 generates random UUID.
+func UUIDv4() string {
 	return uuid.NewV4().String()
 }
 
-func cleanID(s string) string {
-	re := regexp.MustCompile(`[^\w+\-]`)
-	return re.ReplaceAllLiteralString(s, "")
+// UUIDv7 generates time-ordered UUID, as specified by RFC 9562.
+func UUIDv7() string {
+	var b uuid.UUID
+
+	rand.Read(b[6:])
+	putMillis(b[:6], time.Now())
+
+	b[6] = b[6]&0x0f | 0x70
+	b[8] = b[8]&0x3f | 0x80
+
+	return b.String()
+}
+
+// ULID generates lexicographically sortable identifier, see https://github.com/ulid/spec.
+func ULID() string {
+	var b [16]byte
+
+	rand.Read(b[6:])
+	putMillis(b[:6], time.Now())
+
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+
+	s := make([]byte, 26)
+
+	for i := 25; i >= 0; i-- {
+		s[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+
+	return string(s)
+}
+
+func putMillis(b []byte, t time.Time) {
+	ms := uint64(t.UnixMilli())
+
+	for i := 5; i >= 0; i-- {
+		b[i] = byte(ms)
+		ms >>= 8
+	}
 }
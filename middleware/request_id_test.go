@@ -4,7 +4,11 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/bukalapak/ottoman/middleware"
 	uuid "github.com/kevinburke/go.uuid"
@@ -56,4 +60,106 @@ func TestRequestID(t *testing.T) {
 		x(req, uid)
 	})
 
+	t.Run("Response", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Request-Id", uid)
+
+		rec := httptest.NewRecorder()
+		middleware.RequestID(http.HandlerFunc(fn)).ServeHTTP(rec, req)
+
+		assert.Equal(t, uid, rec.Header().Get("X-Request-Id"))
+	})
+
+	t.Run("Capped", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Request-Id", strings.Repeat("a", 100))
+
+		rec := httptest.NewRecorder()
+		middleware.RequestID(http.HandlerFunc(fn)).ServeHTTP(rec, req)
+
+		assert.Equal(t, strings.Repeat("a", 64), rec.Body.String())
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Request-Id", "***")
+
+		x(req, "")
+	})
+}
+
+func TestNewRequestID(t *testing.T) {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, middleware.RequestIDFromContext(r.Context()))
+	}
+
+	h := middleware.NewRequestID(middleware.RequestIDOption{
+		Header:    "X-Trace-Id",
+		Generator: middleware.ULID,
+	})(http.HandlerFunc(fn))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	assert.Regexp(t, `^[0-9A-HJKMNP-TV-Z]{26}$`, rec.Body.String())
+	assert.Equal(t, rec.Body.String(), rec.Header().Get("X-Trace-Id"))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Trace-Id", "abc")
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, "abc", rec.Body.String())
+}
+
+func TestGenerator(t *testing.T) {
+	re := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	for _, fn := range []func() string{middleware.UUIDv7, middleware.ULID} {
+		ids := make([]string, 3)
+
+		for i := range ids {
+			ids[i] = fn()
+			time.Sleep(2 * time.Millisecond)
+		}
+
+		assert.True(t, sort.StringsAreSorted(ids), ids)
+		assert.NotEqual(t, ids[0], ids[1])
+	}
+
+	uid, err := uuid.FromString(middleware.UUIDv7())
+	assert.Nil(t, err)
+	assert.Regexp(t, re, uid.String())
+
+	uid, err = uuid.FromString(middleware.UUIDv4())
+	assert.Nil(t, err)
+	assert.Equal(t, uuid.V4, uid.Version())
+}
+
+func TestRequestIDTransport(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Header.Get("X-Request-Id"))
+	}))
+	defer backend.Close()
+
+	c := &http.Client{Transport: &middleware.RequestIDTransport{}}
+
+	get := func(id string) string {
+		req, _ := http.NewRequest("GET", backend.URL, nil)
+		if id != "" {
+			req = req.WithContext(middleware.NewRequestIDContext(req.Context(), id))
+		}
+
+		resp, err := c.Do(req)
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+
+		b, _ := io.ReadAll(resp.Body)
+
+		return string(b)
+	}
+
+	assert.Equal(t, "abc", get("abc"))
+	assert.Equal(t, "", get(""))
 }
@@ -0,0 +1,175 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	forwarded      = http.CanonicalHeaderKey("Forwarded")
+	contextKeyAddr = ContextKey("RealAddr")
+)
+
+// TrustedRealIP resolves the client IP, honoring forwarding headers only when sent by trusted proxies.
+// Forwarded header (RFC 7239) is preferred over X-Forwarded-For, then X-Real-IP. Forwarding chains are walked from
+// right to left, skipping trusted hops, so entries prepended by the client can't spoof the IP.
+type TrustedRealIP struct {
+	trusted []netip.Prefix
+}
+
+// NewTrustedRealIP returns TrustedRealIP trusting proxies within cidrs, e.g. "10.0.0.0/8" or "::1/128".
+// Single IPs are accepted as well.
+func NewTrustedRealIP(cidrs ...string) (*TrustedRealIP, error) {
+	ps := make([]netip.Prefix, len(cidrs))
+
+	for i, s := range cidrs {
+		if p, err := netip.ParsePrefix(s); err == nil {
+			ps[i] = p.Masked()
+			continue
+		}
+
+		a, err := netip.ParseAddr(s)
+		if err != nil {
+			return nil, errors.Errorf("invalid trusted proxy: %s", s)
+		}
+
+		ps[i] = netip.PrefixFrom(a.Unmap(), a.Unmap().BitLen())
+	}
+
+	return &TrustedRealIP{trusted: ps}, nil
+}
+
+// Handler stores the client IP in the request context, see IPFromContext and AddrFromContext.
+func (x *TrustedRealIP) Handler(h http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		if a := x.Resolve(r); a.IsValid() {
+			ctx = NewAddrContext(NewIPContext(ctx, a.String()), a)
+		}
+
+		h.ServeHTTP(w, r.WithContext(ctx))
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// Resolve returns the client IP of the request. The zero Addr is returned when the peer address is invalid.
+func (x *TrustedRealIP) Resolve(r *http.Request) netip.Addr {
+	peer := parseAddr(r.RemoteAddr)
+	if !x.isTrusted(peer) {
+		return peer
+	}
+
+	if vs := r.Header.Values(forwarded); len(vs) != 0 {
+		return x.walk(peer, forwardedFor(vs))
+	}
+
+	if vs := r.Header.Values(xForwardedFor); len(vs) != 0 {
+		return x.walk(peer, splitList(vs))
+	}
+
+	if a := parseAddr(r.Header.Get(xRealIP)); a.IsValid() {
+		return a
+	}
+
+	return peer
+}
+
+// walk returns the rightmost untrusted hop. Walking stops at invalid hop, e.g. obfuscated identifier.
+func (x *TrustedRealIP) walk(peer netip.Addr, hops []string) netip.Addr {
+	ip := peer
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		a := parseAddr(hops[i])
+		if !a.IsValid() {
+			break
+		}
+
+		ip = a
+
+		if !x.isTrusted(a) {
+			break
+		}
+	}
+
+	return ip
+}
+
+func (x *TrustedRealIP) isTrusted(a netip.Addr) bool {
+	if !a.IsValid() {
+		return false
+	}
+
+	for _, p := range x.trusted {
+		if p.Contains(a) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func NewAddrContext(ctx context.Context, a netip.Addr) context.Context {
+	return context.WithValue(ctx, contextKeyAddr, a)
+}
+
+func AddrFromContext(ctx context.Context) (netip.Addr, bool) {
+	a, ok := ctx.Value(contextKeyAddr).(netip.Addr)
+	return a, ok
+}
+
+// forwardedFor returns the "for" parameters of Forwarded header values.
+func forwardedFor(vs []string) []string {
+	var ss []string
+
+	for _, e := range splitList(vs) {
+		for _, p := range strings.Split(e, ";") {
+			k, v, ok := strings.Cut(strings.TrimSpace(p), "=")
+			if ok && strings.EqualFold(k, "for") {
+				ss = append(ss, v)
+			}
+		}
+	}
+
+	return ss
+}
+
+func splitList(vs []string) []string {
+	var ss []string
+
+	for _, v := range vs {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				ss = append(ss, s)
+			}
+		}
+	}
+
+	return ss
+}
+
+// parseAddr parses IP address optionally having port, brackets or quotes, e.g. `"[2001:db8::1]:4711"`.
+func parseAddr(s string) netip.Addr {
+	s = strings.Trim(strings.TrimSpace(s), `"`)
+
+	if a, err := netip.ParseAddr(s); err == nil {
+		return a.Unmap()
+	}
+
+	if p, err := netip.ParseAddrPort(s); err == nil {
+		return p.Addr().Unmap()
+	}
+
+	if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		if a, err := netip.ParseAddr(s[1 : len(s)-1]); err == nil {
+			return a.Unmap()
+		}
+	}
+
+	return netip.Addr{}
+}
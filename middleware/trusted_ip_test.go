@@ -0,0 +1,65 @@
+package middleware_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/bukalapak/ottoman/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrustedRealIP(t *testing.T) {
+	x, err := middleware.NewTrustedRealIP("10.0.0.0/8", "fd00::/8", "192.0.2.1")
+	assert.Nil(t, err)
+
+	data := []struct {
+		name   string
+		remote string
+		header map[string]string
+		ip     string
+	}{
+		{"Untrusted peer", "203.0.113.9:1234", map[string]string{"X-Forwarded-For": "1.1.1.1"}, "203.0.113.9"},
+		{"Trusted peer without header", "10.0.0.1:1234", nil, "10.0.0.1"},
+		{"X-Forwarded-For", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "1.1.1.1, 203.0.113.9, 10.0.0.2"}, "203.0.113.9"},
+		{"X-Forwarded-For all trusted", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"}, "10.0.0.3"},
+		{"X-Forwarded-For with port", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "203.0.113.9:5678"}, "203.0.113.9"},
+		{"X-Forwarded-For invalid", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "foo, 10.0.0.2"}, "10.0.0.2"},
+		{"X-Real-IP", "10.0.0.1:1234", map[string]string{"X-Real-IP": "203.0.113.9"}, "203.0.113.9"},
+		{"Forwarded", "10.0.0.1:1234", map[string]string{"Forwarded": `for=1.1.1.1, for=203.0.113.9;proto=https, for=10.0.0.2`, "X-Forwarded-For": "1.1.1.1"}, "203.0.113.9"},
+		{"Forwarded IPv6", "[fd00::1]:1234", map[string]string{"Forwarded": `For="[2001:db8:cafe::17]:4711"`}, "2001:db8:cafe::17"},
+		{"Forwarded obfuscated", "10.0.0.1:1234", map[string]string{"Forwarded": `for=_hidden, for=10.0.0.2`}, "10.0.0.2"},
+		{"IPv4 mapped", "[::ffff:10.0.0.1]:1234", map[string]string{"X-Forwarded-For": "2001:db8::1"}, "2001:db8::1"},
+		{"Single trusted IP", "192.0.2.1:1234", map[string]string{"X-Forwarded-For": "203.0.113.9"}, "203.0.113.9"},
+	}
+
+	for _, v := range data {
+		t.Run(v.name, func(t *testing.T) {
+			var addr netip.Addr
+
+			fn := func(w http.ResponseWriter, r *http.Request) {
+				ip, _ := middleware.IPFromContext(r.Context())
+				addr, _ = middleware.AddrFromContext(r.Context())
+				io.WriteString(w, ip)
+			}
+
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = v.remote
+
+			for k, s := range v.header {
+				req.Header.Set(k, s)
+			}
+
+			rec := httptest.NewRecorder()
+			x.Handler(http.HandlerFunc(fn)).ServeHTTP(rec, req)
+
+			assert.Equal(t, v.ip, rec.Body.String())
+			assert.Equal(t, netip.MustParseAddr(v.ip), addr)
+		})
+	}
+
+	_, err = middleware.NewTrustedRealIP("10.0.0.0/33")
+	assert.NotNil(t, err)
+}
@@ -8,6 +8,15 @@ type Notifier interface {
 	Notify(err interface{}, stack []byte)
 }
 
+// Metadata is the additional information of a notified error, e.g. request method and URL.
+type Metadata map[string]interface{}
+
+// MetadataNotifier is a Notifier accepting error metadata.
+type MetadataNotifier interface {
+	Notifier
+	NotifyMetadata(err interface{}, stack []byte, md Metadata)
+}
+
 // Honeybadger wraps honeybadger.Client
 type Honeybadger struct {
 	client  *honeybadger.Client
@@ -24,3 +33,10 @@ func (h *Honeybadger) Notify(err interface{}, stack []byte) {
 		h.client.Notify(err, stack)
 	}
 }
+
+// NotifyMetadata reports the err to the Honeybadger service, along with metadata as the notice context.
+func (h *Honeybadger) NotifyMetadata(err interface{}, stack []byte, md Metadata) {
+	if h.enabled {
+		h.client.Notify(err, stack, honeybadger.Context(md))
+	}
+}
@@ -26,11 +26,34 @@ func TestHoneybadger(t *testing.T) {
 	assert.Equal(t, []string{"sample error 1"}, dc.Errors)
 }
 
+func TestHoneybadger_NotifyMetadata(t *testing.T) {
+	dc := &NullBackend{}
+	hc := honeybadger.New(honeybadger.Configuration{
+		Backend: dc,
+	})
+
+	h1 := notify.NewHoneybadger(hc, true)
+	h2 := notify.NewHoneybadger(hc, false)
+
+	h1.NotifyMetadata(errors.New("sample error 1"), []byte("<stack>"), notify.Metadata{"method": "GET"})
+	h2.NotifyMetadata(errors.New("sample error 2"), []byte("<stack>"), notify.Metadata{"method": "GET"})
+
+	hc.Flush()
+
+	assert.Equal(t, []string{"sample error 1"}, dc.Errors)
+	assert.Equal(t, "GET", dc.Contexts[0]["method"])
+}
+
 type NullBackend struct {
-	Errors []string
+	Errors   []string
+	Contexts []honeybadger.Context
 }
 
 func (b *NullBackend) Notify(f honeybadger.Feature, p honeybadger.Payload) error {
-	b.Errors = append(b.Errors, p.(*honeybadger.Notice).ErrorMessage)
+	n := p.(*honeybadger.Notice)
+
+	b.Errors = append(b.Errors, n.ErrorMessage)
+	b.Contexts = append(b.Contexts, n.Context)
+
 	return nil
 }
@@ -0,0 +1,268 @@
+package proxy
+
+import (
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// RequestTargeter is a Targeter which chooses the target based on the incoming request.
+type RequestTargeter interface {
+	Targeter
+	TargetRequest(r *http.Request) *url.URL
+}
+
+// Releaser is a Targeter which tracks outstanding requests.
+// Proxy calls Release once the request to the target returned by Target is completed.
+type Releaser interface {
+	Targeter
+	Release(u *url.URL)
+}
+
+// exceptTargeter is a RequestTargeter able to choose a target other than the ones already tried.
+type exceptTargeter interface {
+	targetExcept(r *http.Request, tried []*url.URL) *url.URL
+}
+
+// maxRetryPicks bounds the number of targets picked to find one not tried yet.
+const maxRetryPicks = 10
+
+// boundTarget binds a Targeter to the request being forwarded and remembers the chosen targets.
+// Targets already tried are skipped on retries, unless no other target is available.
+type boundTarget struct {
+	target Targeter
+	req    *http.Request
+	url    *url.URL
+	tried  []*url.URL
+	ready  bool
+}
+
+// prepare chooses the target of the next attempt ahead of the director, reporting whether one is available.
+func (t *boundTarget) prepare() bool {
+	t.choose()
+	t.ready = t.url != nil
+
+	return t.ready
+}
+
+// Target returns the target chosen by prepare, or chooses a new one.
+func (t *boundTarget) Target() *url.URL {
+	if t.ready {
+		t.ready = false
+		return t.url
+	}
+
+	return t.choose()
+}
+
+func (t *boundTarget) choose() *url.URL {
+	t.release()
+
+	u := t.pick()
+
+	for i := 0; u != nil && containsURL(t.tried, u) && i < maxRetryPicks; i++ {
+		t.url = u
+		t.release()
+
+		u = t.target.Target()
+	}
+
+	if u != nil {
+		t.tried = append(t.tried, u)
+	}
+
+	t.url = u
+
+	return u
+}
+
+func (t *boundTarget) pick() *url.URL {
+	if x, ok := t.target.(exceptTargeter); ok && len(t.tried) != 0 {
+		return x.targetExcept(t.req, t.tried)
+	}
+
+	if x, ok := t.target.(RequestTargeter); ok {
+		return x.TargetRequest(t.req)
+	}
+
+	return t.target.Target()
+}
+
+func (t *boundTarget) release() {
+	if x, ok := t.target.(Releaser); ok && t.url != nil {
+		x.Release(t.url)
+	}
+
+	t.url = nil
+}
+
+func containsURL(us []*url.URL, u *url.URL) bool {
+	for _, x := range us {
+		if x.String() == u.String() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Weighted is a target along with its relative weight.
+type Weighted struct {
+	URL    *url.URL
+	Weight int
+}
+
+// WeightedTarget is a smooth weighted round robin Targeter.
+type WeightedTarget struct {
+	mu      sync.Mutex
+	targets []*weighted
+	total   int
+}
+
+type weighted struct {
+	Weighted
+	current int
+}
+
+func NewWeightedTarget(ws []Weighted) *WeightedTarget {
+	t := &WeightedTarget{targets: make([]*weighted, 0, len(ws))}
+
+	for _, w := range ws {
+		if w.Weight <= 0 {
+			continue
+		}
+
+		t.targets = append(t.targets, &weighted{Weighted: w})
+		t.total += w.Weight
+	}
+
+	return t
+}
+
+func (t *WeightedTarget) Target() *url.URL {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var best *weighted
+
+	for _, w := range t.targets {
+		w.current += w.Weight
+
+		if best == nil || w.current > best.current {
+			best = w
+		}
+	}
+
+	if best == nil {
+		return nil
+	}
+
+	best.current -= t.total
+
+	return best.URL
+}
+
+// outstanding counts in-flight requests of each target.
+type outstanding struct {
+	mu     sync.Mutex
+	urls   []*url.URL
+	counts []int
+}
+
+func newOutstanding(us []*url.URL) outstanding {
+	return outstanding{urls: us, counts: make([]int, len(us))}
+}
+
+func (o *outstanding) acquire(i int) *url.URL {
+	o.counts[i]++
+	return o.urls[i]
+}
+
+// Release implements Releaser.
+func (o *outstanding) Release(u *url.URL) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for i := range o.urls {
+		if o.urls[i] == u && o.counts[i] > 0 {
+			o.counts[i]--
+			return
+		}
+	}
+}
+
+// LeastTarget is a Targeter choosing the target with the least outstanding requests.
+type LeastTarget struct {
+	outstanding
+	next int
+}
+
+func NewLeastTarget(us []*url.URL) *LeastTarget {
+	return &LeastTarget{outstanding: newOutstanding(us)}
+}
+
+func (t *LeastTarget) Target() *url.URL {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	n := len(t.urls)
+	best := -1
+
+	for i := 0; i < n; i++ {
+		k := (t.next + i) % n
+
+		if best == -1 || t.counts[k] < t.counts[best] {
+			best = k
+		}
+	}
+
+	if best == -1 {
+		return nil
+	}
+
+	t.next = (best + 1) % n
+
+	return t.acquire(best)
+}
+
+// P2CTarget is a Targeter choosing the target with less outstanding requests of two random targets.
+type P2CTarget struct {
+	outstanding
+	rand *rand.Rand
+}
+
+func NewP2CTarget(us []*url.URL) *P2CTarget {
+	return &P2CTarget{
+		outstanding: newOutstanding(us),
+		rand:        rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (t *P2CTarget) Target() *url.URL {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	n := len(t.urls)
+
+	switch n {
+	case 0:
+		return nil
+	case 1:
+		return t.acquire(0)
+	}
+
+	a := t.rand.Intn(n)
+	b := t.rand.Intn(n - 1)
+
+	if b >= a {
+		b++
+	}
+
+	if t.counts[b] < t.counts[a] {
+		a = b
+	}
+
+	return t.acquire(a)
+}
@@ -0,0 +1,100 @@
+package proxy_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/bukalapak/ottoman/proxy"
+	"github.com/stretchr/testify/assert"
+)
+
+func newURLs(n int) []*url.URL {
+	us := make([]*url.URL, n)
+
+	for i := range us {
+		us[i], _ = url.Parse(fmt.Sprintf("http://s%d.example.com/", i+1))
+	}
+
+	return us
+}
+
+func TestWeightedTarget(t *testing.T) {
+	us := newURLs(3)
+	x := proxy.NewWeightedTarget([]proxy.Weighted{
+		{URL: us[0], Weight: 5},
+		{URL: us[1], Weight: 1},
+		{URL: us[2], Weight: 0},
+	})
+
+	hits := make(map[string]int)
+	seq := ""
+
+	for i := 0; i < 6; i++ {
+		z := x.Target()
+		hits[z.Host]++
+		seq += z.Host[:2]
+	}
+
+	assert.Equal(t, map[string]int{"s1.example.com": 5, "s2.example.com": 1}, hits)
+	assert.Equal(t, "s1s1s1s2s1s1", seq)
+	assert.Nil(t, proxy.NewWeightedTarget(nil).Target())
+}
+
+func TestLeastTarget(t *testing.T) {
+	us := newURLs(3)
+	x := proxy.NewLeastTarget(us)
+
+	z1 := x.Target()
+	z2 := x.Target()
+	z3 := x.Target()
+
+	assert.Equal(t, []*url.URL{us[0], us[1], us[2]}, []*url.URL{z1, z2, z3})
+
+	x.Release(z2)
+	assert.Equal(t, us[1], x.Target())
+
+	x.Release(z1)
+	x.Release(z1)
+	assert.Equal(t, us[0], x.Target())
+	assert.Equal(t, us[1], x.Target())
+
+	assert.Nil(t, proxy.NewLeastTarget(nil).Target())
+}
+
+func TestP2CTarget(t *testing.T) {
+	us := newURLs(2)
+	x := proxy.NewP2CTarget(us)
+
+	z1 := x.Target()
+	z2 := x.Target()
+	assert.NotEqual(t, z1, z2)
+
+	x.Release(z1)
+	assert.Equal(t, z1, x.Target())
+
+	assert.Equal(t, us[0], proxy.NewP2CTarget(us[:1]).Target())
+	assert.Nil(t, proxy.NewP2CTarget(nil).Target())
+}
+
+func TestProxy_Releaser(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+
+	u, _ := url.Parse(backend.URL)
+	x := proxy.NewLeastTarget([]*url.URL{u, u})
+	p := proxy.NewProxy(x)
+
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest("GET", "/", nil)
+		rec := httptest.NewRecorder()
+
+		p.Forward(rec, req, Transform{})
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	assert.Equal(t, u, x.Target())
+	assert.Equal(t, u, x.Target())
+}
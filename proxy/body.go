@@ -0,0 +1,191 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bukalapak/ottoman/encoding/json"
+	"github.com/pkg/errors"
+)
+
+const defaultRewriteBodySize = 1 << 20
+
+// JSONRewrite rewrites JSON response bodies, see Modifier. The value returned by Node or Value replaces the body,
+// encoded using json.Marshal. Returned error fails the proxy request.
+type JSONRewrite struct {
+	// Node receives the parsed response body.
+	Node func(n *json.Node) (interface{}, error)
+
+	// Value receives the decoded response body, when Node is nil. Numbers are decoded as json.Number.
+	Value func(v interface{}) (interface{}, error)
+
+	// MaxBodySize is the maximum response body size rewritten, larger responses are passed as is. Default to 1MB.
+	MaxBodySize int64
+}
+
+// RewriteJSON returns Modifier rewriting JSON response bodies using fn.
+func RewriteJSON(fn func(n *json.Node) (interface{}, error)) Modifier {
+	return JSONRewrite{Node: fn}.Modifier()
+}
+
+// RewriteJSONValue returns Modifier rewriting decoded JSON response bodies using fn.
+func RewriteJSONValue(fn func(v interface{}) (interface{}, error)) Modifier {
+	return JSONRewrite{Value: fn}.Modifier()
+}
+
+// Modifier returns Modifier rewriting the response body. Gzip encoded bodies are decoded and encoded back.
+// Responses without body, with non-JSON content type, unsupported content encoding, or exceeding MaxBodySize, before
+// or after decoding, are passed as is, so are streaming responses, e.g. text/event-stream and application/x-ndjson.
+func (j JSONRewrite) Modifier() Modifier {
+	return Modifier{Response: j.rewrite}
+}
+
+func (j JSONRewrite) rewrite(resp *http.Response) error {
+	if !rewritable(resp) || resp.ContentLength > j.maxBodySize() {
+		return nil
+	}
+
+	zipped := strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip")
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, j.maxBodySize()+1))
+	if err != nil {
+		return err
+	}
+
+	if int64(len(b)) > j.maxBodySize() {
+		resp.Body = &readCloser{Reader: io.MultiReader(bytes.NewReader(b), resp.Body), Closer: resp.Body}
+		return nil
+	}
+
+	resp.Body.Close()
+
+	if len(b) == 0 {
+		resp.Body = http.NoBody
+		return nil
+	}
+
+	z := b
+
+	if zipped {
+		if z, err = gunzip(b, j.maxBodySize()); err != nil {
+			return errors.Wrap(err, "gzip")
+		}
+
+		if int64(len(z)) > j.maxBodySize() {
+			resp.Body = io.NopCloser(bytes.NewReader(b))
+			return nil
+		}
+	}
+
+	v, err := j.apply(z)
+	if err != nil {
+		return err
+	}
+
+	if z, err = json.Marshal(v); err != nil {
+		return err
+	}
+
+	if zipped {
+		if z, err = gzipBytes(z); err != nil {
+			return errors.Wrap(err, "gzip")
+		}
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(z))
+	resp.ContentLength = int64(len(z))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(z)))
+	resp.Header.Del("Content-MD5")
+
+	return nil
+}
+
+func (j JSONRewrite) apply(b []byte) (interface{}, error) {
+	if j.Node != nil {
+		n := json.NewNode(bytes.NewReader(b))
+		if err := n.Error(); err != nil {
+			return nil, err
+		}
+
+		return j.Node(n)
+	}
+
+	var v interface{}
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+
+	if j.Value == nil {
+		return v, nil
+	}
+
+	return j.Value(v)
+}
+
+func (j JSONRewrite) maxBodySize() int64 {
+	if j.MaxBodySize <= 0 {
+		return defaultRewriteBodySize
+	}
+
+	return j.MaxBodySize
+}
+
+func rewritable(resp *http.Response) bool {
+	if resp.Body == nil || resp.Body == http.NoBody || resp.Request != nil && resp.Request.Method == http.MethodHead {
+		return false
+	}
+
+	if resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified {
+		return false
+	}
+
+	if e := resp.Header.Get("Content-Encoding"); e != "" && !strings.EqualFold(e, "gzip") && !strings.EqualFold(e, "identity") {
+		return false
+	}
+
+	t, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+
+	return t == "application/json" || strings.HasSuffix(t, "+json")
+}
+
+// gunzip decodes b, reading up to limit+1 bytes so oversized bodies can be detected without decoding them fully.
+func gunzip(b []byte, limit int64) ([]byte, error) {
+	z, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+
+	defer z.Close()
+
+	return io.ReadAll(io.LimitReader(z, limit+1))
+}
+
+func gzipBytes(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+
+	z := gzip.NewWriter(&buf)
+
+	if _, err := z.Write(b); err != nil {
+		return nil, err
+	}
+
+	if err := z.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,130 @@
+package proxy_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/bukalapak/ottoman/encoding/json"
+	"github.com/bukalapak/ottoman/proxy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRewriteJSON(t *testing.T) {
+	body := `{"id":1,"name":"foo","secret":"bar"}`
+
+	var bomb bytes.Buffer
+
+	z := gzip.NewWriter(&bomb)
+	io.WriteString(z, `{"data":"`+strings.Repeat("x", 1<<20)+`"}`)
+	z.Close()
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/text":
+			w.Header().Set("Content-Type", "text/plain")
+			io.WriteString(w, body)
+		case "/gzip":
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Content-Encoding", "gzip")
+
+			z := gzip.NewWriter(w)
+			io.WriteString(z, body)
+			z.Close()
+		case "/bomb":
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(bomb.Bytes())
+		case "/large":
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"data":"`+strings.Repeat("x", 100)+`"}`)
+		default:
+			w.Header().Set("Content-Type", "application/vnd.api+json; charset=utf-8")
+			io.WriteString(w, body)
+		}
+	}))
+	defer backend.Close()
+
+	u, _ := url.Parse(backend.URL)
+
+	strip := proxy.RewriteJSON(func(n *json.Node) (interface{}, error) {
+		return map[string]interface{}{"id": n.Get("id").Int(), "name": n.Get("name").String()}, nil
+	})
+
+	forward := func(path string, ms ...proxy.Modifier) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+
+		rec := httptest.NewRecorder()
+		proxy.NewProxy(proxy.NewTarget(u)).Forward(rec, req, proxy.Chain(proxy.NewTransformer(nil), ms...))
+
+		return rec
+	}
+
+	t.Run("Node", func(t *testing.T) {
+		rec := forward("/", strip)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"id":1,"name":"foo"}`, rec.Body.String())
+		assert.Equal(t, strconv.Itoa(rec.Body.Len()), rec.Header().Get("Content-Length"))
+	})
+
+	t.Run("Value", func(t *testing.T) {
+		rec := forward("/", proxy.RewriteJSONValue(func(v interface{}) (interface{}, error) {
+			m := v.(map[string]interface{})
+			delete(m, "secret")
+			m["extra"] = true
+
+			return m, nil
+		}))
+
+		assert.JSONEq(t, `{"id":1,"name":"foo","extra":true}`, rec.Body.String())
+	})
+
+	t.Run("Gzip", func(t *testing.T) {
+		rec := forward("/gzip", strip)
+
+		assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+		assert.Equal(t, strconv.Itoa(rec.Body.Len()), rec.Header().Get("Content-Length"))
+
+		z, err := gzip.NewReader(bytes.NewReader(rec.Body.Bytes()))
+		assert.Nil(t, err)
+
+		b, _ := io.ReadAll(z)
+		assert.JSONEq(t, `{"id":1,"name":"foo"}`, string(b))
+	})
+
+	t.Run("Skipped", func(t *testing.T) {
+		rec := forward("/text", strip)
+		assert.Equal(t, body, rec.Body.String())
+
+		x := proxy.JSONRewrite{Node: func(n *json.Node) (interface{}, error) { return nil, nil }, MaxBodySize: 50}
+		rec = forward("/large", x.Modifier())
+		assert.Equal(t, 111, rec.Body.Len())
+	})
+
+	t.Run("Skipped (decoded size)", func(t *testing.T) {
+		x := proxy.JSONRewrite{Node: func(n *json.Node) (interface{}, error) { return nil, nil }, MaxBodySize: 4096}
+		assert.Less(t, bomb.Len(), 4096)
+
+		rec := forward("/bomb", x.Modifier())
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+		assert.Equal(t, bomb.Bytes(), rec.Body.Bytes())
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		rec := forward("/", proxy.RewriteJSON(func(n *json.Node) (interface{}, error) {
+			return nil, errors.New("rewrite error")
+		}))
+
+		assert.Equal(t, http.StatusBadGateway, rec.Code)
+	})
+}
@@ -0,0 +1,473 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bukalapak/ottoman/cache"
+)
+
+const (
+	defaultCacheBodySize = 1 << 20
+	cacheStatusHeader    = "X-Cache"
+)
+
+// Cache is a Forwarder storing upstream responses of GET and HEAD requests in a cache.Provider.
+// It honors Cache-Control and Vary headers, collapses concurrent misses of the same key into a single upstream
+// request and serves stale response when the upstream fails. The cache status is reported in X-Cache header.
+type Cache struct {
+	Forwarder Forwarder
+	Provider  cache.Provider
+
+	// Key maps request to cache key. Keys are normalized by the Provider, so they should not contain ":".
+	// Default to a hash of method, host and request URI.
+	Key func(r *http.Request) string
+
+	// Expiration is used for responses without explicit caching headers. Zero means they're not stored.
+	Expiration time.Duration
+
+	// StaleTTL is how long responses are kept after they expire, to be served when the upstream fails.
+	StaleTTL time.Duration
+
+	// StatusCodes are the cacheable response status codes. Default to 200.
+	StatusCodes []int
+
+	// MaxBodySize is the maximum response body size stored. Larger responses are streamed to the client without
+	// being buffered. Default to 1MB.
+	MaxBodySize int64
+
+	flight flight
+}
+
+func NewCache(f Forwarder, p cache.Provider) *Cache {
+	return &Cache{Forwarder: f, Provider: p}
+}
+
+type cacheEntry struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+	Vary       []string    `json:"vary,omitempty"`
+	Stored     time.Time   `json:"stored"`
+	Expires    time.Time   `json:"expires"`
+}
+
+type cacheResult struct {
+	entry    *cacheEntry
+	status   string
+	variant  string
+	storable bool
+}
+
+func (c *Cache) Forward(w http.ResponseWriter, r *http.Request, n Transformer) {
+	if !c.cacheable(r) {
+		c.Forwarder.Forward(w, r, n)
+		return
+	}
+
+	now := time.Now()
+	key := c.key(r)
+
+	e, variant := c.lookup(key, r)
+	if e != nil && now.Before(e.Expires) && !requestNoCache(r) {
+		c.write(w, e, "HIT", now)
+		return
+	}
+
+	x, shared := c.flight.do(variant, func() *cacheResult {
+		return c.fetch(w, r, n, key, e)
+	})
+
+	if !shared {
+		return
+	}
+
+	if x == nil || !x.storable || x.variant != variantKey(key, x.entry.Vary, r) {
+		c.Forwarder.Forward(w, r, n)
+		return
+	}
+
+	c.write(w, x.entry, x.status, now)
+}
+
+func (c *Cache) cacheable(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if r.Header.Get("Authorization") != "" || isUpgrade(r) {
+		return false
+	}
+
+	_, ok := parseCacheControl(r.Header.Get("Cache-Control"))["no-store"]
+
+	return !ok
+}
+
+func (c *Cache) key(r *http.Request) string {
+	if c.Key != nil {
+		return c.Key(r)
+	}
+
+	return hashKey(r.Method, r.Host, r.URL.RequestURI())
+}
+
+// lookup returns the stored entry, fresh or stale, along with the key of the request variant.
+func (c *Cache) lookup(key string, r *http.Request) (*cacheEntry, string) {
+	e := c.read(key)
+	if e == nil || len(e.Vary) == 0 {
+		return e, key
+	}
+
+	variant := variantKey(key, e.Vary, r)
+
+	return c.read(variant), variant
+}
+
+func (c *Cache) read(key string) *cacheEntry {
+	b, err := c.Provider.Read(key)
+	if err != nil {
+		return nil
+	}
+
+	e := &cacheEntry{}
+	if err := json.Unmarshal(b, e); err != nil {
+		return nil
+	}
+
+	return e
+}
+
+// fetch forwards the request, streaming the response to w while capturing it for storing.
+// Server errors are held back from w when the stale entry can be served instead.
+func (c *Cache) fetch(w http.ResponseWriter, r *http.Request, n Transformer, key string, stale *cacheEntry) *cacheResult {
+	hasStale := stale != nil && stale.StatusCode != 0
+
+	b := newCaptureWriter(w, c.maxBodySize(), hasStale)
+	c.Forwarder.Forward(b, r, n)
+
+	now := time.Now()
+	e := b.entry(now)
+
+	if b.discard {
+		c.write(w, stale, "STALE", now)
+		return &cacheResult{entry: stale, status: "STALE", variant: variantKey(key, stale.Vary, r), storable: true}
+	}
+
+	e.Vary = varyHeaders(e.Header)
+	x := &cacheResult{entry: e, status: "MISS", variant: variantKey(key, e.Vary, r)}
+
+	if !b.capture {
+		return x
+	}
+
+	ttl, ok := c.ttl(e, now)
+	if !ok {
+		return x
+	}
+
+	e.Expires = now.Add(ttl)
+	x.storable = c.store(key, x.variant, e, ttl+c.StaleTTL) == nil
+
+	return x
+}
+
+func (c *Cache) ttl(e *cacheEntry, now time.Time) (time.Duration, bool) {
+	if !c.storableStatus(e.StatusCode) {
+		return 0, false
+	}
+
+	if e.Header.Get("Set-Cookie") != "" || contains(e.Vary, "*") {
+		return 0, false
+	}
+
+	ttl, ok := cache.HeaderTTL(e.Header, now)
+	if !ok {
+		ttl = c.Expiration
+	}
+
+	return ttl, ttl > 0
+}
+
+func (c *Cache) store(key, variant string, e *cacheEntry, expiration time.Duration) error {
+	if variant != key {
+		b, err := json.Marshal(&cacheEntry{Vary: e.Vary, Stored: e.Stored, Expires: e.Expires})
+		if err != nil {
+			return err
+		}
+
+		if err := c.Provider.Write(key, b, expiration); err != nil {
+			return err
+		}
+	}
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	return c.Provider.Write(variant, b, expiration)
+}
+
+func (c *Cache) write(w http.ResponseWriter, e *cacheEntry, status string, now time.Time) {
+	h := w.Header()
+
+	for k, v := range e.Header {
+		h[k] = append([]string(nil), v...)
+	}
+
+	if status != "MISS" {
+		h.Set("Age", strconv.FormatInt(int64(now.Sub(e.Stored)/time.Second), 10))
+	}
+
+	h.Set(cacheStatusHeader, status)
+
+	w.WriteHeader(e.StatusCode)
+	w.Write(e.Body)
+}
+
+func (c *Cache) storableStatus(code int) bool {
+	if len(c.StatusCodes) == 0 {
+		return code == http.StatusOK
+	}
+
+	for _, n := range c.StatusCodes {
+		if n == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *Cache) maxBodySize() int64 {
+	if c.MaxBodySize <= 0 {
+		return defaultCacheBodySize
+	}
+
+	return c.MaxBodySize
+}
+
+func requestNoCache(r *http.Request) bool {
+	cc := parseCacheControl(r.Header.Get("Cache-Control"))
+	_, ok := cc["no-cache"]
+
+	return ok || r.Header.Get("Pragma") == "no-cache"
+}
+
+func varyHeaders(h http.Header) []string {
+	var vs []string
+
+	for _, v := range h.Values("Vary") {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				vs = append(vs, http.CanonicalHeaderKey(s))
+			}
+		}
+	}
+
+	return vs
+}
+
+func variantKey(key string, vary []string, r *http.Request) string {
+	if len(vary) == 0 {
+		return key
+	}
+
+	ss := make([]string, 0, len(vary)*2)
+	for _, v := range vary {
+		ss = append(ss, v, strings.Join(r.Header.Values(v), ","))
+	}
+
+	return key + "-" + hashKey(ss...)
+}
+
+func hashKey(ss ...string) string {
+	h := sha1.New()
+
+	for _, s := range ss {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func parseCacheControl(s string) map[string]string {
+	m := make(map[string]string)
+
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+
+		if n := strings.SplitN(v, "=", 2); len(n) == 2 {
+			m[strings.ToLower(n[0])] = strings.Trim(n[1], `"`)
+		} else {
+			m[strings.ToLower(v)] = ""
+		}
+	}
+
+	return m
+}
+
+func contains(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// streamingTypes are the content types streamed to the client without capturing.
+var streamingTypes = []string{"text/event-stream", "multipart/x-mixed-replace", "application/x-ndjson", "application/grpc"}
+
+// captureWriter is a http.ResponseWriter streaming the upstream response to the client while capturing it.
+// Capturing stops once the body exceeds the limit, the captured body is then released.
+type captureWriter struct {
+	w       http.ResponseWriter
+	header  http.Header
+	code    int
+	body    bytes.Buffer
+	limit   int64
+	hold    bool
+	capture bool
+	discard bool
+}
+
+func newCaptureWriter(w http.ResponseWriter, limit int64, hold bool) *captureWriter {
+	return &captureWriter{w: w, header: make(http.Header), limit: limit, hold: hold}
+}
+
+func (b *captureWriter) Header() http.Header {
+	return b.header
+}
+
+func (b *captureWriter) WriteHeader(code int) {
+	if b.code != 0 {
+		return
+	}
+
+	b.code = code
+
+	if code >= http.StatusInternalServerError && b.hold {
+		b.discard = true
+		return
+	}
+
+	b.capture = !isStreaming(b.header) && contentLength(b.header) <= b.limit
+
+	h := b.w.Header()
+
+	for k, v := range b.header {
+		h[k] = append([]string(nil), v...)
+	}
+
+	h.Set(cacheStatusHeader, "MISS")
+
+	b.w.WriteHeader(code)
+}
+
+func (b *captureWriter) Write(p []byte) (int, error) {
+	b.WriteHeader(http.StatusOK)
+
+	if b.discard {
+		return len(p), nil
+	}
+
+	if b.capture {
+		if int64(b.body.Len()+len(p)) > b.limit {
+			b.capture = false
+			b.body = bytes.Buffer{}
+		} else {
+			b.body.Write(p)
+		}
+	}
+
+	return b.w.Write(p)
+}
+
+func (b *captureWriter) Flush() {
+	b.WriteHeader(http.StatusOK)
+
+	if f, ok := b.w.(http.Flusher); ok && !b.discard {
+		f.Flush()
+	}
+}
+
+func (b *captureWriter) entry(now time.Time) *cacheEntry {
+	code := b.code
+	if code == 0 {
+		code = http.StatusOK
+	}
+
+	return &cacheEntry{StatusCode: code, Header: b.header, Body: b.body.Bytes(), Stored: now}
+}
+
+func isStreaming(h http.Header) bool {
+	t, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	return contains(streamingTypes, t)
+}
+
+func contentLength(h http.Header) int64 {
+	n, err := strconv.ParseInt(h.Get("Content-Length"), 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return n
+}
+
+// flight collapses concurrent calls of the same key into one.
+type flight struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	wg  sync.WaitGroup
+	res *cacheResult
+}
+
+// do executes fn once for concurrent calls of key. The returned bool reports whether the result is shared.
+func (f *flight) do(key string, fn func() *cacheResult) (*cacheResult, bool) {
+	f.mu.Lock()
+
+	if f.calls == nil {
+		f.calls = make(map[string]*flightCall)
+	}
+
+	if c, ok := f.calls[key]; ok {
+		f.mu.Unlock()
+		c.wg.Wait()
+
+		return c.res, true
+	}
+
+	c := &flightCall{}
+	c.wg.Add(1)
+	f.calls[key] = c
+	f.mu.Unlock()
+
+	defer func() {
+		f.mu.Lock()
+		delete(f.calls, key)
+		f.mu.Unlock()
+		c.wg.Done()
+	}()
+
+	c.res = fn()
+
+	return c.res, false
+}
@@ -0,0 +1,300 @@
+package proxy_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bukalapak/ottoman/cache"
+	"github.com/bukalapak/ottoman/proxy"
+	"github.com/stretchr/testify/assert"
+)
+
+type memory struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+func newMemory() *memory {
+	return &memory{data: make(map[string][]byte)}
+}
+
+func (m *memory) Name() string { return "memory" }
+
+func (m *memory) Write(key string, value []byte, expiration time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.data[key] = value
+
+	return nil
+}
+
+func (m *memory) Read(key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if b, ok := m.data[key]; ok {
+		return b, nil
+	}
+
+	return nil, errors.New("cache miss")
+}
+
+func (m *memory) ReadMulti(keys []string) (map[string][]byte, error) {
+	z := make(map[string][]byte)
+
+	for _, k := range keys {
+		if b, err := m.Read(k); err == nil {
+			z[k] = b
+		}
+	}
+
+	return z, nil
+}
+
+func (m *memory) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.data, key)
+
+	return nil
+}
+
+func TestCache(t *testing.T) {
+	var hits int32
+
+	status := int32(http.StatusOK)
+
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&hits, 1)
+
+		switch r.URL.Path {
+		case "/slow":
+			time.Sleep(50 * time.Millisecond)
+		case "/private":
+			w.Header().Set("Cache-Control", "private")
+		case "/vary":
+			w.Header().Set("Vary", "Accept-Language")
+		case "/expired":
+			w.Header().Set("Cache-Control", "max-age=0")
+		}
+
+		if r.URL.Path != "/private" && r.URL.Path != "/expired" {
+			w.Header().Set("Cache-Control", "max-age=60")
+		}
+
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+		io.WriteString(w, r.URL.Path+" "+r.Header.Get("Accept-Language")+" "+string(rune('0'+n)))
+	}))
+	defer up.Close()
+
+	u, _ := url.Parse(up.URL)
+
+	newCache := func() *proxy.Cache {
+		c := proxy.NewCache(proxy.NewProxy(proxy.NewTarget(u)), cache.NewProvider(newMemory(), "proxy"))
+		c.StaleTTL = time.Minute
+
+		return c
+	}
+
+	get := func(c *proxy.Cache, path string, h ...string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", path, nil)
+		for i := 0; i+1 < len(h); i += 2 {
+			req.Header.Set(h[i], h[i+1])
+		}
+
+		rec := httptest.NewRecorder()
+		c.Forward(rec, req, Transform{})
+
+		return rec
+	}
+
+	reset := func() {
+		atomic.StoreInt32(&hits, 0)
+		atomic.StoreInt32(&status, http.StatusOK)
+	}
+
+	t.Run("Hit", func(t *testing.T) {
+		reset()
+		c := newCache()
+
+		rec := get(c, "/foo")
+		assert.Equal(t, "MISS", rec.Header().Get("X-Cache"))
+		assert.Equal(t, "/foo  1", rec.Body.String())
+
+		rec = get(c, "/foo")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "HIT", rec.Header().Get("X-Cache"))
+		assert.Equal(t, "/foo  1", rec.Body.String())
+		assert.Equal(t, "0", rec.Header().Get("Age"))
+		assert.Equal(t, "1", rec.Header().Get("X-Modified"))
+
+		rec = get(c, "/foo", "Cache-Control", "no-cache")
+		assert.Equal(t, "MISS", rec.Header().Get("X-Cache"))
+		assert.Equal(t, "/foo  2", rec.Body.String())
+	})
+
+	t.Run("Not stored", func(t *testing.T) {
+		reset()
+		c := newCache()
+
+		get(c, "/private")
+		rec := get(c, "/private")
+		assert.Equal(t, "MISS", rec.Header().Get("X-Cache"))
+		assert.Equal(t, "/private  2", rec.Body.String())
+
+		get(c, "/foo", "Authorization", "Bearer x")
+		rec = get(c, "/foo", "Authorization", "Bearer x")
+		assert.Empty(t, rec.Header().Get("X-Cache"))
+		assert.Equal(t, "/foo  4", rec.Body.String())
+	})
+
+	t.Run("Vary", func(t *testing.T) {
+		reset()
+		c := newCache()
+
+		get(c, "/vary", "Accept-Language", "en")
+		get(c, "/vary", "Accept-Language", "id")
+
+		rec := get(c, "/vary", "Accept-Language", "en")
+		assert.Equal(t, "HIT", rec.Header().Get("X-Cache"))
+		assert.Equal(t, "/vary en 1", rec.Body.String())
+
+		rec = get(c, "/vary", "Accept-Language", "id")
+		assert.Equal(t, "HIT", rec.Header().Get("X-Cache"))
+		assert.Equal(t, "/vary id 2", rec.Body.String())
+	})
+
+	t.Run("Collapse", func(t *testing.T) {
+		reset()
+		c := newCache()
+
+		var wg sync.WaitGroup
+
+		bodies := make([]string, 5)
+
+		for i := range bodies {
+			wg.Add(1)
+
+			go func(i int) {
+				defer wg.Done()
+				bodies[i] = get(c, "/slow").Body.String()
+			}(i)
+		}
+
+		wg.Wait()
+
+		assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+
+		for _, b := range bodies {
+			assert.Equal(t, "/slow  1", b)
+		}
+	})
+
+	t.Run("Stale", func(t *testing.T) {
+		reset()
+
+		c := newCache()
+		c.Expiration = time.Minute
+
+		rec := get(c, "/expired")
+		assert.Equal(t, "MISS", rec.Header().Get("X-Cache"))
+
+		c.Key = func(r *http.Request) string { return "stale" }
+		c.Provider.Write("stale", []byte(`{"status_code":200,"header":{},"body":"c3RhbGU=","expires":"2000-01-01T00:00:00Z"}`), time.Minute)
+
+		atomic.StoreInt32(&status, http.StatusBadGateway)
+
+		rec = get(c, "/foo")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "STALE", rec.Header().Get("X-Cache"))
+		assert.Equal(t, "stale", rec.Body.String())
+
+		atomic.StoreInt32(&status, http.StatusOK)
+
+		rec = get(c, "/foo")
+		assert.Equal(t, "MISS", rec.Header().Get("X-Cache"))
+		assert.Equal(t, "/foo  3", rec.Body.String())
+	})
+
+	t.Run("Non cacheable method", func(t *testing.T) {
+		reset()
+		c := newCache()
+
+		req, _ := http.NewRequest("POST", "/foo", nil)
+		rec := httptest.NewRecorder()
+		c.Forward(rec, req, Transform{})
+
+		assert.Empty(t, rec.Header().Get("X-Cache"))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+	})
+}
+
+func TestCache_streaming(t *testing.T) {
+	next := make(chan struct{})
+
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/events" {
+			w.Header().Set("Content-Type", "text/event-stream")
+		}
+
+		w.Header().Set("Cache-Control", "max-age=60")
+
+		for i := 0; i < 4; i++ {
+			io.WriteString(w, strings.Repeat(string(rune('a'+i)), 10))
+			w.(http.Flusher).Flush()
+
+			if i == 0 {
+				<-next
+			}
+		}
+	}))
+	defer up.Close()
+
+	u, _ := url.Parse(up.URL)
+
+	m := newMemory()
+
+	c := proxy.NewCache(proxy.NewProxy(proxy.NewTarget(u)), cache.NewProvider(m, "proxy"))
+	c.MaxBodySize = 15
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Forward(w, r, Transform{})
+	}))
+	defer srv.Close()
+
+	for _, path := range []string{"/large", "/events"} {
+		t.Run(path, func(t *testing.T) {
+			resp, err := http.Get(srv.URL + path)
+			assert.Nil(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, "MISS", resp.Header.Get("X-Cache"))
+
+			// the first chunk arrives while the upstream is still writing
+			b := make([]byte, 10)
+			_, err = io.ReadFull(resp.Body, b)
+			assert.Nil(t, err)
+			assert.Equal(t, strings.Repeat("a", 10), string(b))
+
+			next <- struct{}{}
+
+			rest, err := io.ReadAll(resp.Body)
+			assert.Nil(t, err)
+			assert.Equal(t, strings.Repeat("b", 10)+strings.Repeat("c", 10)+strings.Repeat("d", 10), string(rest))
+		})
+	}
+
+	m.mu.Lock()
+	assert.Empty(t, m.data)
+	m.mu.Unlock()
+}
@@ -0,0 +1,260 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const defaultDiscoveryInterval = 30 * time.Second
+
+// Updater is a Targeter whose members can be replaced at runtime, e.g. RingTarget.
+type Updater interface {
+	Targeter
+	Update(us []*url.URL)
+}
+
+// Source is the interface for discovering upstream URLs.
+type Source interface {
+	Discover(ctx context.Context) ([]*url.URL, error)
+}
+
+// Update atomically replaces the members. Existing members keep their health state, new members start healthy.
+// Requests already forwarded to removed members are not affected. Empty list is ignored.
+func (t *RingTarget) Update(us []*url.URL) {
+	if len(us) == 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ms := make([]*member, len(us))
+
+	for i, u := range us {
+		if m := t.lookup(u); m != nil {
+			ms[i] = m
+		} else {
+			ms[i] = &member{url: u, healthy: true}
+		}
+	}
+
+	t.members = ms
+	t.next = t.next % len(ms)
+}
+
+// FileSource reads upstream URLs from a file, containing either JSON array of strings, or one URL per line.
+// Blank lines and lines starting with "#" are ignored.
+type FileSource struct {
+	Path string
+}
+
+// Discover implements Source.
+func (s *FileSource) Discover(ctx context.Context) ([]*url.URL, error) {
+	b, err := os.ReadFile(s.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var ss []string
+
+	if b = bytes.TrimSpace(b); bytes.HasPrefix(b, []byte("[")) {
+		if err := json.Unmarshal(b, &ss); err != nil {
+			return nil, errors.Wrap(err, "invalid upstream file")
+		}
+	} else {
+		z := bufio.NewScanner(bytes.NewReader(b))
+
+		for z.Scan() {
+			if v := strings.TrimSpace(z.Text()); v != "" && !strings.HasPrefix(v, "#") {
+				ss = append(ss, v)
+			}
+		}
+	}
+
+	return parseURLs(ss)
+}
+
+// DNSSource resolves upstream URLs from DNS SRV records when Service is set, otherwise from A and AAAA records.
+type DNSSource struct {
+	// Name is the domain name, e.g. "api.service.consul".
+	Name string
+
+	// Service and Proto are the SRV record service and protocol, e.g. "http" and "tcp".
+	Service string
+	Proto   string
+
+	// Scheme is the URL scheme of the upstreams. Default to http.
+	Scheme string
+
+	// Port is the port of A and AAAA record upstreams. Zero means the default port of Scheme.
+	Port int
+
+	// Resolver is the DNS resolver. Default to net.DefaultResolver.
+	Resolver *net.Resolver
+}
+
+// Discover implements Source.
+func (s *DNSSource) Discover(ctx context.Context) ([]*url.URL, error) {
+	var hosts []string
+
+	if s.Service != "" {
+		_, rs, err := s.resolver().LookupSRV(ctx, s.Service, s.Proto, s.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, r := range rs {
+			hosts = append(hosts, net.JoinHostPort(strings.TrimSuffix(r.Target, "."), strconv.Itoa(int(r.Port))))
+		}
+	} else {
+		as, err := s.resolver().LookupIPAddr(ctx, s.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, a := range as {
+			hosts = append(hosts, s.host(a.IP.String()))
+		}
+	}
+
+	sort.Strings(hosts)
+
+	us := make([]*url.URL, len(hosts))
+	for i, h := range hosts {
+		us[i] = &url.URL{Scheme: s.scheme(), Host: h}
+	}
+
+	return us, nil
+}
+
+func (s *DNSSource) host(ip string) string {
+	if s.Port > 0 {
+		return net.JoinHostPort(ip, strconv.Itoa(s.Port))
+	}
+
+	if strings.Contains(ip, ":") {
+		return "[" + ip + "]"
+	}
+
+	return ip
+}
+
+func (s *DNSSource) scheme() string {
+	if s.Scheme == "" {
+		return "http"
+	}
+
+	return s.Scheme
+}
+
+func (s *DNSSource) resolver() *net.Resolver {
+	if s.Resolver == nil {
+		return net.DefaultResolver
+	}
+
+	return s.Resolver
+}
+
+func parseURLs(ss []string) ([]*url.URL, error) {
+	us := make([]*url.URL, len(ss))
+
+	for i, s := range ss {
+		u, err := url.Parse(s)
+		if err != nil {
+			return nil, err
+		}
+
+		if u.Scheme == "" || u.Host == "" {
+			return nil, errors.Errorf("invalid upstream: %s", s)
+		}
+
+		us[i] = u
+	}
+
+	return us, nil
+}
+
+// Watcher periodically updates an Updater from a Source.
+type Watcher struct {
+	target Updater
+	source Source
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu  sync.Mutex
+	err error
+}
+
+// Watch updates t from s immediately, then on every interval until stopped. Zero interval means 30s.
+// On discovery failure or empty result, the current members are kept.
+func Watch(t Updater, s Source, interval time.Duration) *Watcher {
+	if interval <= 0 {
+		interval = defaultDiscoveryInterval
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &Watcher{target: t, source: s, cancel: cancel, done: make(chan struct{})}
+
+	w.sync(ctx)
+
+	go w.run(ctx, interval)
+
+	return w
+}
+
+// Stop stops watching, waiting for the running discovery to finish.
+func (w *Watcher) Stop() {
+	w.cancel()
+	<-w.done
+}
+
+// Err returns the error of the last discovery, if any.
+func (w *Watcher) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.err
+}
+
+func (w *Watcher) run(ctx context.Context, interval time.Duration) {
+	defer close(w.done)
+
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+			w.sync(ctx)
+		}
+	}
+}
+
+func (w *Watcher) sync(ctx context.Context) {
+	us, err := w.source.Discover(ctx)
+	if err == nil && len(us) == 0 {
+		err = errors.New("no upstream discovered")
+	}
+
+	w.mu.Lock()
+	w.err = err
+	w.mu.Unlock()
+
+	if err == nil {
+		w.target.Update(us)
+	}
+}
@@ -0,0 +1,144 @@
+package proxy_test
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bukalapak/ottoman/proxy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRingTarget_Update(t *testing.T) {
+	us := newURLs(3)
+
+	x := proxy.NewRingTarget(us[:2])
+	x.StartHealthCheck(proxy.HealthCheck{Interval: time.Hour, Transport: okTransport{}, MaxFails: 1})
+	defer x.StopHealthCheck()
+
+	x.MarkFailure(us[1])
+	assert.Equal(t, us[:1], x.Members())
+
+	x.Update([]*url.URL{us[1], us[2]})
+	assert.Equal(t, us[2:], x.Members())
+
+	x.Update(nil)
+	assert.Equal(t, us[2:], x.Members())
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, us[2], x.Target())
+	}
+}
+
+func TestFileSource(t *testing.T) {
+	dir := t.TempDir()
+
+	data := map[string]string{
+		"upstream.json": `["http://10.0.0.1:8080", "http://10.0.0.2:8080"]`,
+		"upstream.txt":  "# upstreams\nhttp://10.0.0.1:8080\n\nhttp://10.0.0.2:8080\n",
+	}
+
+	for name, s := range data {
+		path := filepath.Join(dir, name)
+		assert.Nil(t, os.WriteFile(path, []byte(s), 0600))
+
+		us, err := (&proxy.FileSource{Path: path}).Discover(context.Background())
+		assert.Nil(t, err)
+		assert.Len(t, us, 2)
+		assert.Equal(t, "http://10.0.0.2:8080", us[1].String())
+	}
+
+	bad := map[string]string{
+		"bad.json": `["http://10.0.0.1:8080"`,
+		"bad.txt":  "10.0.0.1",
+	}
+
+	for name, s := range bad {
+		path := filepath.Join(dir, name)
+		assert.Nil(t, os.WriteFile(path, []byte(s), 0600))
+
+		_, err := (&proxy.FileSource{Path: path}).Discover(context.Background())
+		assert.NotNil(t, err)
+	}
+
+	_, err := (&proxy.FileSource{Path: filepath.Join(dir, "unknown")}).Discover(context.Background())
+	assert.NotNil(t, err)
+}
+
+func TestDNSSource(t *testing.T) {
+	s := &proxy.DNSSource{Name: "localhost", Port: 8080}
+
+	us, err := s.Discover(context.Background())
+	assert.Nil(t, err)
+	assert.Contains(t, us, &url.URL{Scheme: "http", Host: "127.0.0.1:8080"})
+
+	s = &proxy.DNSSource{Name: "localhost", Scheme: "https"}
+
+	us, err = s.Discover(context.Background())
+	assert.Nil(t, err)
+	assert.Contains(t, us, &url.URL{Scheme: "https", Host: "127.0.0.1"})
+}
+
+func TestWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "upstream.txt")
+	assert.Nil(t, os.WriteFile(path, []byte("http://10.0.0.1"), 0600))
+
+	x := proxy.NewRingTarget(newURLs(1))
+
+	w := proxy.Watch(x, &proxy.FileSource{Path: path}, 10*time.Millisecond)
+	defer w.Stop()
+
+	assert.Nil(t, w.Err())
+	assert.Equal(t, "http://10.0.0.1", x.Target().String())
+
+	assert.Nil(t, os.WriteFile(path, []byte("http://10.0.0.2"), 0600))
+	assert.Eventually(t, func() bool { return x.Target().String() == "http://10.0.0.2" }, time.Second, 10*time.Millisecond)
+
+	assert.Nil(t, os.Remove(path))
+	assert.Eventually(t, func() bool { return w.Err() != nil }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "http://10.0.0.2", x.Target().String())
+}
+
+func TestWatch_empty(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer up.Close()
+
+	path := filepath.Join(t.TempDir(), "upstream.txt")
+
+	x := proxy.NewRingTarget(nil)
+
+	w := proxy.Watch(x, &proxy.FileSource{Path: path}, 10*time.Millisecond)
+	defer w.Stop()
+
+	assert.NotNil(t, w.Err())
+	assert.Nil(t, x.Target())
+
+	p := proxy.NewProxy(x)
+	p.Logger = log.New(io.Discard, "", 0)
+
+	forward := func() *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/", nil)
+		rec := httptest.NewRecorder()
+		p.Forward(rec, req, proxy.NewTransformer(nil))
+
+		return rec
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, forward().Code)
+
+	assert.Nil(t, os.WriteFile(path, []byte(up.URL), 0600))
+	assert.Eventually(t, func() bool { return x.Target() != nil }, time.Second, 10*time.Millisecond)
+
+	rec := forward()
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "ok", rec.Body.String())
+}
@@ -0,0 +1,115 @@
+package proxy
+
+import (
+	"hash/crc32"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/bukalapak/ottoman/middleware"
+)
+
+const defaultReplicas = 100
+
+// KeyFunc extracts the hashing key from the request.
+type KeyFunc func(r *http.Request) string
+
+// HeaderKey uses the request header value as hashing key.
+func HeaderKey(name string) KeyFunc {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// CookieKey uses the request cookie value as hashing key.
+func CookieKey(name string) KeyFunc {
+	return func(r *http.Request) string {
+		c, err := r.Cookie(name)
+		if err != nil {
+			return ""
+		}
+
+		return c.Value
+	}
+}
+
+// PathKey uses the request path as hashing key.
+func PathKey(r *http.Request) string {
+	return r.URL.Path
+}
+
+// IPKey uses the client IP set by middleware.RealIP as hashing key, falling back to the remote address.
+func IPKey(r *http.Request) string {
+	if ip, ok := middleware.IPFromContext(r.Context()); ok {
+		return ip
+	}
+
+	ip, _, _ := net.SplitHostPort(r.RemoteAddr)
+
+	return ip
+}
+
+// HashTarget is a consistent hashing RequestTargeter, routing requests with the same key to the same target.
+type HashTarget struct {
+	key    KeyFunc
+	hashes []uint32
+	urls   map[uint32]*url.URL
+}
+
+func NewHashTarget(us []*url.URL, key KeyFunc) *HashTarget {
+	t := &HashTarget{
+		key:  key,
+		urls: make(map[uint32]*url.URL, len(us)*defaultReplicas),
+	}
+
+	for _, u := range us {
+		for i := 0; i < defaultReplicas; i++ {
+			h := crc32.ChecksumIEEE([]byte(strconv.Itoa(i) + u.String()))
+
+			t.hashes = append(t.hashes, h)
+			t.urls[h] = u
+		}
+	}
+
+	sort.Slice(t.hashes, func(i, j int) bool { return t.hashes[i] < t.hashes[j] })
+
+	return t
+}
+
+// Target returns the target of an empty key.
+func (t *HashTarget) Target() *url.URL {
+	return t.lookup("")
+}
+
+func (t *HashTarget) TargetRequest(r *http.Request) *url.URL {
+	return t.lookup(t.key(r))
+}
+
+func (t *HashTarget) lookup(key string) *url.URL {
+	return t.lookupExcept(key, nil)
+}
+
+// targetExcept returns the next target along the ring not in tried, used on retries.
+func (t *HashTarget) targetExcept(r *http.Request, tried []*url.URL) *url.URL {
+	return t.lookupExcept(t.key(r), tried)
+}
+
+func (t *HashTarget) lookupExcept(key string, tried []*url.URL) *url.URL {
+	n := len(t.hashes)
+	if n == 0 {
+		return nil
+	}
+
+	h := crc32.ChecksumIEEE([]byte(key))
+	i := sort.Search(n, func(i int) bool { return t.hashes[i] >= h })
+
+	for k := 0; k < n; k++ {
+		if u := t.urls[t.hashes[(i+k)%n]]; !containsURL(tried, u) {
+			return u
+		}
+	}
+
+	return t.urls[t.hashes[i%n]]
+}
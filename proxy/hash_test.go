@@ -0,0 +1,94 @@
+package proxy_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/bukalapak/ottoman/middleware"
+	"github.com/bukalapak/ottoman/proxy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashTarget(t *testing.T) {
+	us := newURLs(4)
+	x := proxy.NewHashTarget(us, proxy.HeaderKey("X-User-Id"))
+
+	hits := make(map[string]int)
+
+	for i := 0; i < 100; i++ {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("X-User-Id", fmt.Sprintf("user-%d", i))
+
+		z1 := x.TargetRequest(req)
+		z2 := x.TargetRequest(req)
+
+		assert.Equal(t, z1, z2)
+		hits[z1.Host]++
+	}
+
+	assert.Len(t, hits, 4)
+	assert.NotNil(t, x.Target())
+
+	t.Run("Stable membership", func(t *testing.T) {
+		y := proxy.NewHashTarget(us[:3], proxy.HeaderKey("X-User-Id"))
+		moved := 0
+
+		for i := 0; i < 100; i++ {
+			req, _ := http.NewRequest("GET", "/", nil)
+			req.Header.Set("X-User-Id", fmt.Sprintf("user-%d", i))
+
+			if z := x.TargetRequest(req); z != us[3] && z != y.TargetRequest(req) {
+				moved++
+			}
+		}
+
+		assert.Zero(t, moved)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		assert.Nil(t, proxy.NewHashTarget(nil, proxy.PathKey).Target())
+	})
+}
+
+func TestKeyFunc(t *testing.T) {
+	req := httptest.NewRequest("GET", "/foo/bar", nil)
+	req.Header.Set("X-User-Id", "42")
+	req.AddCookie(&http.Cookie{Name: "session", Value: "abc"})
+
+	assert.Equal(t, "42", proxy.HeaderKey("X-User-Id")(req))
+	assert.Equal(t, "abc", proxy.CookieKey("session")(req))
+	assert.Equal(t, "", proxy.CookieKey("unknown")(req))
+	assert.Equal(t, "/foo/bar", proxy.PathKey(req))
+	assert.Equal(t, "192.0.2.1", proxy.IPKey(req))
+
+	ctx := middleware.NewIPContext(context.Background(), "202.212.212.202")
+	assert.Equal(t, "202.212.212.202", proxy.IPKey(req.WithContext(ctx)))
+}
+
+func TestProxy_RequestTargeter(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+
+	u, _ := url.Parse(backend.URL)
+	dead, _ := url.Parse("http://127.0.0.1:1")
+
+	x := proxy.NewHashTarget([]*url.URL{u, dead}, proxy.PathKey)
+	p := proxy.NewProxy(x)
+
+	for _, path := range []string{"/a", "/b", "/c", "/d", "/e"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		rec := httptest.NewRecorder()
+
+		p.Forward(rec, req, Transform{})
+
+		if x.TargetRequest(req) == u {
+			assert.Equal(t, http.StatusOK, rec.Code)
+		} else {
+			assert.Equal(t, http.StatusBadGateway, rec.Code)
+		}
+	}
+}
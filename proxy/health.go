@@ -0,0 +1,217 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	defaultHealthInterval = 10 * time.Second
+	defaultHealthTimeout  = 2 * time.Second
+	defaultMaxFails       = 3
+	defaultMinPasses      = 2
+)
+
+// HealthReporter receives passive health information of the upstream serving a proxied request.
+type HealthReporter interface {
+	MarkSuccess(u *url.URL)
+	MarkFailure(u *url.URL)
+}
+
+// HealthCheck is the configuration for checking RingTarget members health.
+type HealthCheck struct {
+	// Path is probed using GET request. A 2xx or 3xx status code is considered healthy.
+	Path      string
+	Interval  time.Duration
+	Timeout   time.Duration
+	Transport http.RoundTripper
+
+	// MaxFails is the number of consecutive failed probes or proxy errors before a member is removed.
+	MaxFails int
+
+	// MinPasses is the number of consecutive successful probes before a removed member is reinstated.
+	MinPasses int
+}
+
+func (h HealthCheck) interval() time.Duration {
+	if h.Interval <= 0 {
+		return defaultHealthInterval
+	}
+
+	return h.Interval
+}
+
+func (h HealthCheck) maxFails() int {
+	if h.MaxFails <= 0 {
+		return defaultMaxFails
+	}
+
+	return h.MaxFails
+}
+
+func (h HealthCheck) minPasses() int {
+	if h.MinPasses <= 0 {
+		return defaultMinPasses
+	}
+
+	return h.MinPasses
+}
+
+func (h HealthCheck) httpClient() *http.Client {
+	c := &http.Client{
+		Transport: h.Transport,
+		Timeout:   h.Timeout,
+	}
+
+	if c.Transport == nil {
+		c.Transport = http.DefaultTransport
+	}
+
+	if c.Timeout <= 0 {
+		c.Timeout = defaultHealthTimeout
+	}
+
+	return c
+}
+
+type healthChecker struct {
+	option HealthCheck
+	client *http.Client
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// StartHealthCheck starts probing members periodically, and enables passive health reporting from Proxy.
+// Calling it again replaces the running health check.
+func (t *RingTarget) StartHealthCheck(h HealthCheck) {
+	t.StopHealthCheck()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &healthChecker{
+		option: h,
+		client: h.httpClient(),
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	t.mu.Lock()
+	t.health = c
+	t.mu.Unlock()
+
+	go t.runHealthCheck(ctx, c)
+}
+
+// StopHealthCheck stops the running health check, if any.
+func (t *RingTarget) StopHealthCheck() {
+	t.mu.Lock()
+	c := t.health
+	t.health = nil
+	t.mu.Unlock()
+
+	if c != nil {
+		c.cancel()
+		<-c.done
+	}
+}
+
+// MarkSuccess implements HealthReporter.
+func (t *RingTarget) MarkSuccess(u *url.URL) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if m := t.lookup(u); m != nil && m.healthy {
+		m.fails = 0
+	}
+}
+
+// MarkFailure implements HealthReporter. It's ignored unless health check is started.
+func (t *RingTarget) MarkFailure(u *url.URL) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.health == nil {
+		return
+	}
+
+	if m := t.lookup(u); m != nil {
+		t.fail(m, t.health.option)
+	}
+}
+
+func (t *RingTarget) fail(m *member, h HealthCheck) {
+	m.passes = 0
+	m.fails++
+
+	if m.fails >= h.maxFails() {
+		m.healthy = false
+	}
+}
+
+func (t *RingTarget) pass(m *member, h HealthCheck) {
+	m.fails = 0
+	m.passes++
+
+	if !m.healthy && m.passes >= h.minPasses() {
+		m.healthy = true
+	}
+}
+
+func (t *RingTarget) runHealthCheck(ctx context.Context, c *healthChecker) {
+	defer close(c.done)
+
+	tick := time.NewTicker(c.option.interval())
+	defer tick.Stop()
+
+	for {
+		t.probeAll(ctx, c)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+func (t *RingTarget) probeAll(ctx context.Context, c *healthChecker) {
+	t.mu.Lock()
+	ms := make([]*member, len(t.members))
+	copy(ms, t.members)
+	t.mu.Unlock()
+
+	for _, m := range ms {
+		ok := c.probe(ctx, m.url)
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		t.mu.Lock()
+		if ok {
+			t.pass(m, c.option)
+		} else {
+			t.fail(m, c.option)
+		}
+		t.mu.Unlock()
+	}
+}
+
+func (c *healthChecker) probe(ctx context.Context, u *url.URL) bool {
+	z := *u
+	z.Path = c.option.Path
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, z.String(), nil)
+	if err != nil {
+		return false
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode >= 200 && resp.StatusCode < 400
+}
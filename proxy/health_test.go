@@ -0,0 +1,175 @@
+package proxy_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bukalapak/ottoman/proxy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRingTarget_HealthCheck(t *testing.T) {
+	var down int32 = 1
+
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" && atomic.LoadInt32(&down) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		io.WriteString(w, "OK")
+	}
+
+	s1 := httptest.NewServer(http.HandlerFunc(fn))
+	defer s1.Close()
+
+	s2 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer s2.Close()
+
+	u1, _ := url.Parse(s1.URL)
+	u2, _ := url.Parse(s2.URL)
+
+	x := proxy.NewRingTarget([]*url.URL{u1, u2})
+	x.StartHealthCheck(proxy.HealthCheck{
+		Path:      "/healthz",
+		Interval:  5 * time.Millisecond,
+		MaxFails:  1,
+		MinPasses: 1,
+	})
+	defer x.StopHealthCheck()
+
+	assert.Eventually(t, func() bool {
+		return len(x.Members()) == 1
+	}, time.Second, 5*time.Millisecond)
+
+	assert.Equal(t, u2, x.Members()[0])
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, u2, x.Target())
+	}
+
+	atomic.StoreInt32(&down, 0)
+
+	assert.Eventually(t, func() bool {
+		return len(x.Members()) == 2
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestRingTarget_PassiveHealth(t *testing.T) {
+	u1, _ := url.Parse("http://s1.example.com")
+	u2, _ := url.Parse("http://s2.example.com")
+
+	t.Run("Without health check", func(t *testing.T) {
+		x := proxy.NewRingTarget([]*url.URL{u1, u2})
+		x.MarkFailure(u1)
+		x.MarkFailure(u1)
+		x.MarkFailure(u1)
+
+		assert.Len(t, x.Members(), 2)
+	})
+
+	t.Run("Proxy errors", func(t *testing.T) {
+		x := proxy.NewRingTarget([]*url.URL{u1, u2})
+		x.StartHealthCheck(proxy.HealthCheck{
+			Interval:  time.Hour,
+			MaxFails:  2,
+			Transport: okTransport{},
+		})
+		defer x.StopHealthCheck()
+
+		p := proxy.NewProxy(x)
+		p.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+
+		for i := 0; i < 4; i++ {
+			rec := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/", nil)
+
+			p.Forward(rec, req, FailingTransform{})
+			assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		}
+
+		assert.Empty(t, x.Members())
+		assert.NotNil(t, x.Target())
+	})
+
+	t.Run("Proxy errors with retry", func(t *testing.T) {
+		x := proxy.NewRingTarget([]*url.URL{u1, u2})
+		x.StartHealthCheck(proxy.HealthCheck{
+			Interval:  time.Hour,
+			MaxFails:  2,
+			Transport: okTransport{},
+		})
+		defer x.StopHealthCheck()
+
+		p := proxy.NewProxy(x)
+		p.Retry = proxy.RetryPolicy{MaxAttempt: 2}
+		p.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+
+		forward := func() {
+			rec := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/", nil)
+
+			p.Forward(rec, req, FailingTransform{})
+			assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		}
+
+		// each member fails once
+		forward()
+		assert.Len(t, x.Members(), 2)
+
+		forward()
+		assert.Empty(t, x.Members())
+	})
+
+	t.Run("Proxy success", func(t *testing.T) {
+		x := proxy.NewRingTarget([]*url.URL{u1})
+		x.StartHealthCheck(proxy.HealthCheck{
+			Interval:  time.Hour,
+			MaxFails:  2,
+			Transport: okTransport{},
+		})
+		defer x.StopHealthCheck()
+
+		p := proxy.NewProxy(x)
+
+		x.MarkFailure(u1)
+
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		p.Forward(rec, req, StubTransform{})
+
+		x.MarkFailure(u1)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Len(t, x.Members(), 1)
+	})
+}
+
+type okTransport struct{}
+
+func (okTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader("")),
+		Request:    r,
+	}, nil
+}
+
+type StubTransform struct {
+	Transform
+}
+
+func (s StubTransform) RoundTrip(r *http.Request) (*http.Response, error) {
+	return okTransport{}.RoundTrip(r)
+}
@@ -0,0 +1,316 @@
+package proxy
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptrace"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bukalapak/ottoman/encoding/json"
+	"github.com/bukalapak/ottoman/middleware"
+	"github.com/bukalapak/ottoman/tracker"
+	"github.com/bukalapak/ottoman/tracker/datadog"
+	xhttp "github.com/bukalapak/ottoman/x/http"
+)
+
+// Hook is the interface for receiving the report of every request forwarded by Proxy, e.g. for access logging
+// and metrics. Report is called synchronously once the response is written.
+type Hook interface {
+	Report(n *Report)
+}
+
+// Report describes a forwarded request.
+type Report struct {
+	// Request is the incoming request.
+	Request *http.Request
+
+	// Upstream is the target of the last attempt, nil when no attempt is made.
+	Upstream *url.URL
+
+	// StatusCode is the status code written to the client.
+	StatusCode int
+
+	// Size is the number of response body bytes written to the client.
+	Size int
+
+	// Attempts is the number of upstream requests made, including retries.
+	Attempts int
+
+	// Connect is the time spent establishing new upstream connections, zero when connections are reused.
+	Connect time.Duration
+
+	// TTFB is the time until the first upstream response byte is received.
+	TTFB time.Duration
+
+	// Duration is the total time spent forwarding the request.
+	Duration time.Duration
+
+	// Err is the upstream error, if any.
+	Err error
+
+	// Time is when forwarding started.
+	Time time.Time
+}
+
+// Record is the flat representation of Report, suitable for structured logs and trackers.
+type Record struct {
+	Time       time.Time `json:"time"`
+	RequestID  string    `json:"request_id,omitempty"`
+	RemoteIP   string    `json:"remote_ip,omitempty"`
+	Method     string    `json:"method"`
+	Host       string    `json:"host"`
+	Path       string    `json:"path"`
+	Upstream   string    `json:"upstream,omitempty"`
+	Status     int       `json:"status"`
+	Size       int       `json:"size"`
+	Attempts   int       `json:"attempts"`
+	ConnectMS  float64   `json:"connect_ms"`
+	TTFBMS     float64   `json:"ttfb_ms"`
+	DurationMS float64   `json:"duration_ms"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Record returns the flat representation of the report.
+func (n *Report) Record() *Record {
+	x := &Record{
+		Time:       n.Time,
+		RequestID:  middleware.RequestIDFromContext(n.Request.Context()),
+		Method:     n.Request.Method,
+		Host:       n.Request.Host,
+		Path:       n.Request.URL.Path,
+		Status:     n.StatusCode,
+		Size:       n.Size,
+		Attempts:   n.Attempts,
+		ConnectMS:  milliseconds(n.Connect),
+		TTFBMS:     milliseconds(n.TTFB),
+		DurationMS: milliseconds(n.Duration),
+	}
+
+	if ip, ok := middleware.IPFromContext(n.Request.Context()); ok {
+		x.RemoteIP = ip
+	}
+
+	if n.Upstream != nil {
+		x.Upstream = n.Upstream.String()
+	}
+
+	if n.Err != nil {
+		x.Error = n.Err.Error()
+	}
+
+	return x
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// LogHook writes reports as JSON lines.
+type LogHook struct {
+	logger *log.Logger
+}
+
+func NewLogHook(w io.Writer) *LogHook {
+	return &LogHook{logger: log.New(w, "", 0)}
+}
+
+// Report implements Hook.
+func (h *LogHook) Report(n *Report) {
+	b, err := json.Marshal(n.Record())
+	if err != nil {
+		return
+	}
+
+	h.logger.Print(string(b))
+}
+
+const defaultTrackerBufferSize = 1000
+
+// TrackerOption is the configuration option for TrackerHook.
+type TrackerOption struct {
+	// Payload builds the tracker payload of a report. Default to DatadogSeries.
+	Payload func(n *Report) interface{}
+
+	// BufferSize is the number of reports pending to be sent. Reports are dropped when the buffer is full.
+	// Default to 1000.
+	BufferSize int
+
+	// Logger logs tracking errors. Default to the standard logger.
+	Logger *log.Logger
+}
+
+// TrackerHook sends reports to a tracker, e.g. datadog.Datadog, in the background so requests are not held by
+// the tracker. Close stops it once the pending reports are sent.
+type TrackerHook struct {
+	tracker  tracker.Tracker
+	option   TrackerOption
+	payloads chan interface{}
+	done     chan struct{}
+	mu       sync.RWMutex
+	closed   bool
+}
+
+func NewTrackerHook(t tracker.Tracker, opt TrackerOption) *TrackerHook {
+	if opt.Payload == nil {
+		opt.Payload = DatadogSeries
+	}
+
+	if opt.BufferSize <= 0 {
+		opt.BufferSize = defaultTrackerBufferSize
+	}
+
+	h := &TrackerHook{
+		tracker:  t,
+		option:   opt,
+		payloads: make(chan interface{}, opt.BufferSize),
+		done:     make(chan struct{}),
+	}
+
+	go h.run()
+
+	return h
+}
+
+// Report implements Hook. It never blocks, reports are dropped when the buffer is full or the hook is closed.
+func (h *TrackerHook) Report(n *Report) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if h.closed {
+		return
+	}
+
+	select {
+	case h.payloads <- h.option.Payload(n):
+	default:
+		h.logf("proxy: tracker buffer is full, report dropped")
+	}
+}
+
+// Close sends the pending reports and stops the hook.
+func (h *TrackerHook) Close() {
+	h.mu.Lock()
+
+	if !h.closed {
+		h.closed = true
+		close(h.payloads)
+	}
+
+	h.mu.Unlock()
+
+	<-h.done
+}
+
+func (h *TrackerHook) run() {
+	defer close(h.done)
+
+	for v := range h.payloads {
+		if _, err := h.tracker.Track(v); err != nil {
+			h.logf("proxy: tracker error: %v", err)
+		}
+	}
+}
+
+func (h *TrackerHook) logf(format string, v ...interface{}) {
+	if h.option.Logger != nil {
+		h.option.Logger.Printf(format, v...)
+	} else {
+		log.Printf(format, v...)
+	}
+}
+
+// DatadogSeries builds datadog.Series of the report: "proxy.request.count" count and "proxy.request.duration"
+// gauge in milliseconds, tagged by method, status and upstream host.
+func DatadogSeries(n *Report) interface{} {
+	ts := n.Time.Unix()
+	tags := []string{"method:" + n.Request.Method, "status:" + strconv.Itoa(n.StatusCode)}
+
+	if n.Upstream != nil {
+		tags = append(tags, "upstream:"+n.Upstream.Host)
+	}
+
+	return &datadog.Series{
+		Series: []datadog.Metric{
+			{Metric: "proxy.request.count", Type: datadog.Count, Points: [][2]int64{{ts, 1}}, Tags: tags},
+			{Metric: "proxy.request.duration", Type: datadog.Gauge, Points: [][2]int64{{ts, n.Duration.Milliseconds()}}, Tags: tags},
+		},
+	}
+}
+
+// observer collects the report of a single Forward call.
+type observer struct {
+	http.RoundTripper
+	mu      sync.Mutex
+	report  Report
+	start   time.Time
+	getConn time.Time
+}
+
+func (p *Proxy) observe(proxy *httputil.ReverseProxy, w http.ResponseWriter, r *http.Request) (xhttp.ResponseWriter, *http.Request, *observer) {
+	o := &observer{RoundTripper: proxy.Transport, start: time.Now()}
+	o.report.Request = r
+	o.report.Time = o.start
+
+	proxy.Transport = o
+
+	errorHandler := p.errorHandler()
+	if proxy.ErrorHandler != nil {
+		errorHandler = proxy.ErrorHandler
+	}
+
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		o.mu.Lock()
+		o.report.Err = err
+		o.mu.Unlock()
+
+		errorHandler(w, r, err)
+	}
+
+	trace := &httptrace.ClientTrace{
+		GetConn: func(string) {
+			o.mu.Lock()
+			o.getConn = time.Now()
+			o.mu.Unlock()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			o.mu.Lock()
+			if !info.Reused {
+				o.report.Connect += time.Since(o.getConn)
+			}
+			o.mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			o.mu.Lock()
+			o.report.TTFB = time.Since(o.start)
+			o.mu.Unlock()
+		},
+	}
+
+	return xhttp.NewResponseWriter(w), r.WithContext(httptrace.WithClientTrace(r.Context(), trace)), o
+}
+
+func (o *observer) RoundTrip(r *http.Request) (*http.Response, error) {
+	o.mu.Lock()
+	o.report.Attempts++
+	o.report.Upstream = &url.URL{Scheme: r.URL.Scheme, Host: r.URL.Host}
+	o.mu.Unlock()
+
+	return o.RoundTripper.RoundTrip(r)
+}
+
+func (o *observer) done(w xhttp.ResponseWriter, h Hook) {
+	o.mu.Lock()
+	n := o.report
+	o.mu.Unlock()
+
+	n.StatusCode = w.Status()
+	n.Size = w.Size()
+	n.Duration = time.Since(o.start)
+
+	h.Report(&n)
+}
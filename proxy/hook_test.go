@@ -0,0 +1,196 @@
+package proxy_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bukalapak/ottoman/middleware"
+	"github.com/bukalapak/ottoman/proxy"
+	"github.com/bukalapak/ottoman/tracker/datadog"
+	"github.com/stretchr/testify/assert"
+)
+
+type hookFunc func(n *proxy.Report)
+
+func (fn hookFunc) Report(n *proxy.Report) {
+	fn(n)
+}
+
+type trackerFunc func(data interface{}) ([]byte, error)
+
+func (fn trackerFunc) Track(data interface{}) ([]byte, error) {
+	return fn(data)
+}
+
+type roundTripFunc func(r *http.Request) (*http.Response, error)
+
+func (fn roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return fn(r)
+}
+
+func TestProxy_Hook(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		io.WriteString(w, "hello")
+	}))
+	defer backend.Close()
+
+	u, _ := url.Parse(backend.URL)
+	dead, _ := url.Parse("http://127.0.0.1:1")
+
+	t.Run("Success", func(t *testing.T) {
+		var n *proxy.Report
+
+		x := proxy.NewProxy(proxy.NewTarget(u))
+		x.Hook = hookFunc(func(v *proxy.Report) { n = v })
+
+		req := httptest.NewRequest("GET", "/foo", nil)
+		rec := httptest.NewRecorder()
+		x.Forward(rec, req, Transform{})
+
+		assert.Equal(t, req, n.Request)
+		assert.Equal(t, backend.URL, n.Upstream.String())
+		assert.Equal(t, http.StatusOK, n.StatusCode)
+		assert.Equal(t, 5, n.Size)
+		assert.Equal(t, 1, n.Attempts)
+		assert.Nil(t, n.Err)
+		assert.True(t, n.TTFB > 0)
+		assert.True(t, n.Duration >= n.TTFB)
+		assert.True(t, n.Duration >= n.Connect)
+	})
+
+	t.Run("Retry", func(t *testing.T) {
+		var n *proxy.Report
+
+		x := proxy.NewProxy(proxy.NewRingTarget([]*url.URL{dead, u}))
+		x.Retry = proxy.RetryPolicy{MaxAttempt: 3}
+		x.Hook = hookFunc(func(v *proxy.Report) { n = v })
+
+		rec := httptest.NewRecorder()
+		x.Forward(rec, httptest.NewRequest("GET", "/foo", nil), Transform{})
+
+		assert.Equal(t, http.StatusOK, n.StatusCode)
+		assert.Equal(t, 2, n.Attempts)
+		assert.Equal(t, backend.URL, n.Upstream.String())
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		var n *proxy.Report
+
+		x := proxy.NewProxy(proxy.NewTarget(dead))
+		x.Hook = hookFunc(func(v *proxy.Report) { n = v })
+
+		rec := httptest.NewRecorder()
+		x.Forward(rec, httptest.NewRequest("GET", "/foo", nil), Transform{})
+
+		assert.Equal(t, http.StatusBadGateway, n.StatusCode)
+		assert.Equal(t, 1, n.Attempts)
+		assert.NotNil(t, n.Err)
+		assert.Equal(t, dead.String(), n.Upstream.String())
+	})
+
+	t.Run("Log", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		x := proxy.NewProxy(proxy.NewTarget(u))
+		x.Hook = proxy.NewLogHook(&buf)
+
+		req := httptest.NewRequest("GET", "/fail", nil)
+		req = req.WithContext(middleware.NewRequestIDContext(req.Context(), "abc"))
+		req = req.WithContext(middleware.NewIPContext(req.Context(), "10.0.0.1"))
+
+		x.Forward(httptest.NewRecorder(), req, Transform{})
+
+		var v map[string]interface{}
+
+		assert.Nil(t, json.Unmarshal(buf.Bytes(), &v))
+		assert.Equal(t, "abc", v["request_id"])
+		assert.Equal(t, "10.0.0.1", v["remote_ip"])
+		assert.Equal(t, "GET", v["method"])
+		assert.Equal(t, "/fail", v["path"])
+		assert.Equal(t, backend.URL, v["upstream"])
+		assert.Equal(t, float64(http.StatusServiceUnavailable), v["status"])
+		assert.Contains(t, v, "duration_ms")
+	})
+
+	t.Run("Tracker", func(t *testing.T) {
+		var mu sync.Mutex
+		var series []datadog.Series
+
+		release := make(chan struct{})
+
+		dd := datadog.New("proxy", "secret", datadog.Option{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			<-release
+
+			var v datadog.Series
+			json.NewDecoder(r.Body).Decode(&v)
+
+			mu.Lock()
+			series = append(series, v)
+			mu.Unlock()
+
+			return &http.Response{StatusCode: http.StatusAccepted, Body: io.NopCloser(strings.NewReader(`{}`))}, nil
+		})})
+
+		h := proxy.NewTrackerHook(dd, proxy.TrackerOption{})
+
+		x := proxy.NewProxy(proxy.NewTarget(u))
+		x.Hook = h
+
+		for i := 0; i < 2; i++ {
+			rec := httptest.NewRecorder()
+			x.Forward(rec, httptest.NewRequest("GET", "/foo", nil), Transform{})
+			assert.Equal(t, http.StatusOK, rec.Code)
+		}
+
+		close(release)
+		h.Close()
+
+		assert.Len(t, series, 2)
+
+		m := series[0].Series
+		assert.Len(t, m, 2)
+		assert.Equal(t, "proxy.request.count", m[0].Metric)
+		assert.Equal(t, datadog.Count, m[0].Type)
+		assert.Equal(t, int64(1), m[0].Points[0][1])
+		assert.Equal(t, "proxy.request.duration", m[1].Metric)
+		assert.Contains(t, m[0].Tags, "status:200")
+		assert.Contains(t, m[0].Tags, "upstream:"+u.Host)
+
+		h.Report(&proxy.Report{Request: httptest.NewRequest("GET", "/", nil)})
+	})
+
+	t.Run("Tracker (full)", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		release := make(chan struct{})
+
+		h := proxy.NewTrackerHook(trackerFunc(func(v interface{}) ([]byte, error) {
+			<-release
+			return nil, errors.New("track error")
+		}), proxy.TrackerOption{BufferSize: 1, Logger: log.New(&buf, "", 0)})
+
+		for i := 0; i < 3; i++ {
+			h.Report(&proxy.Report{Request: httptest.NewRequest("GET", "/", nil)})
+		}
+
+		close(release)
+		h.Close()
+
+		assert.Contains(t, buf.String(), "report dropped")
+		assert.Contains(t, buf.String(), "tracker error: track error")
+	})
+}
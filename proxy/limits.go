@@ -0,0 +1,156 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"strconv"
+	"time"
+
+	"github.com/bukalapak/ottoman/http/breaker"
+)
+
+// ErrResponseHeaderTimeout is returned when upstream does not send response headers within Limits.ResponseHeaderTimeout.
+var ErrResponseHeaderTimeout = errors.New("proxy: timeout awaiting response headers")
+
+// Limits is the configuration for upstream timeouts and request body size of Proxy.
+// Upgrade requests, e.g. WebSocket, are not subject to timeouts, see Proxy.IdleTimeout.
+type Limits struct {
+	// ResponseHeaderTimeout is the time to wait for upstream response headers, for every attempt.
+	ResponseHeaderTimeout time.Duration
+
+	// Timeout is the total time of forwarding a request, including retries and response body.
+	Timeout time.Duration
+
+	// MaxBodySize is the maximum request body size. Larger requests are rejected with 413 status code.
+	MaxBodySize int64
+
+	// DeadlineHeader is the request header propagating the remaining time in milliseconds to upstream,
+	// e.g. "X-Request-Timeout". Incoming value of the header shortens Timeout.
+	DeadlineHeader string
+}
+
+func (n Limits) enabled() bool {
+	return n.ResponseHeaderTimeout > 0 || n.Timeout > 0 || n.MaxBodySize > 0 || n.DeadlineHeader != ""
+}
+
+// limit applies the limits to proxy and incoming request. It returns false when the request is rejected.
+func (p *Proxy) limit(proxy *httputil.ReverseProxy, w http.ResponseWriter, r *http.Request) (*http.Request, context.CancelFunc, bool) {
+	n := p.Limits
+
+	if n.MaxBodySize > 0 && r.ContentLength > n.MaxBodySize {
+		http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+		return r, nil, false
+	}
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+
+	if d, ok := n.timeout(r); ok {
+		ctx, cancel = context.WithTimeout(r.Context(), d)
+	} else {
+		ctx, cancel = context.WithCancel(r.Context())
+	}
+
+	r = r.WithContext(ctx)
+
+	if n.MaxBodySize > 0 && r.Body != nil && r.Body != http.NoBody {
+		r.Body = http.MaxBytesReader(w, r.Body, n.MaxBodySize)
+	}
+
+	if n.ResponseHeaderTimeout > 0 {
+		proxy.Transport = &headerTimeout{RoundTripper: proxy.Transport, timeout: n.ResponseHeaderTimeout}
+	}
+
+	if n.DeadlineHeader != "" {
+		director := proxy.Director
+		proxy.Director = func(r *http.Request) {
+			director(r)
+
+			if t, ok := r.Context().Deadline(); ok {
+				r.Header.Set(n.DeadlineHeader, strconv.FormatInt(time.Until(t).Milliseconds(), 10))
+			}
+		}
+	}
+
+	return r, cancel, true
+}
+
+func (n Limits) timeout(r *http.Request) (time.Duration, bool) {
+	d := n.Timeout
+
+	if n.DeadlineHeader != "" {
+		if v, err := strconv.ParseInt(r.Header.Get(n.DeadlineHeader), 10, 64); err == nil && v > 0 {
+			if x := time.Duration(v) * time.Millisecond; d <= 0 || x < d {
+				d = x
+			}
+		}
+	}
+
+	return d, d > 0
+}
+
+func errorStatus(err error) int {
+	var e *http.MaxBytesError
+
+	switch {
+	case errors.As(err, &e):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, ErrResponseHeaderTimeout):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, breaker.ErrOpen), errors.Is(err, ErrNoTarget):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+// headerTimeout cancels upstream requests not receiving response headers in time.
+type headerTimeout struct {
+	http.RoundTripper
+	timeout time.Duration
+}
+
+func (x *headerTimeout) RoundTrip(r *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(r.Context())
+
+	timer := time.AfterFunc(x.timeout, cancel)
+
+	resp, err := x.RoundTripper.RoundTrip(r.WithContext(ctx))
+	if !timer.Stop() {
+		if err == nil {
+			resp.Body.Close()
+		}
+
+		cancel()
+
+		if r.Context().Err() != nil {
+			return nil, r.Context().Err()
+		}
+
+		return nil, ErrResponseHeaderTimeout
+	}
+
+	if err != nil {
+		cancel()
+		return resp, err
+	}
+
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+
+	return resp, nil
+}
+
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+
+	return err
+}
@@ -0,0 +1,116 @@
+package proxy_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bukalapak/ottoman/proxy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxy_Limits(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if d, err := time.ParseDuration(r.URL.Query().Get("sleep")); err == nil {
+			select {
+			case <-time.After(d):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			return
+		}
+
+		w.Header().Set("X-Deadline", r.Header.Get("X-Request-Timeout"))
+		w.Write(b)
+	}))
+	defer backend.Close()
+
+	u, _ := url.Parse(backend.URL)
+
+	forward := func(n proxy.Limits, req *http.Request) *httptest.ResponseRecorder {
+		x := proxy.NewProxy(proxy.NewTarget(u))
+		x.Limits = n
+
+		rec := httptest.NewRecorder()
+		x.Forward(rec, req, Transform{})
+
+		return rec
+	}
+
+	t.Run("Body size", func(t *testing.T) {
+		n := proxy.Limits{MaxBodySize: 5}
+
+		rec := forward(n, httptest.NewRequest("POST", "/", strings.NewReader("hello")))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "hello", rec.Body.String())
+
+		rec = forward(n, httptest.NewRequest("POST", "/", strings.NewReader("hello world")))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+		req := httptest.NewRequest("POST", "/", io.NopCloser(strings.NewReader("hello world")))
+		req.ContentLength = -1
+
+		rec = forward(n, req)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		n := proxy.Limits{Timeout: 50 * time.Millisecond}
+
+		rec := forward(n, httptest.NewRequest("GET", "/", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		rec = forward(n, httptest.NewRequest("GET", "/?sleep=1s", nil))
+		assert.Equal(t, http.StatusGatewayTimeout, rec.Code)
+	})
+
+	t.Run("Response header timeout", func(t *testing.T) {
+		var err error
+
+		x := proxy.NewProxy(proxy.NewTarget(u))
+		x.Limits = proxy.Limits{ResponseHeaderTimeout: 50 * time.Millisecond}
+		x.Hook = hookFunc(func(n *proxy.Report) { err = n.Err })
+
+		rec := httptest.NewRecorder()
+		x.Forward(rec, httptest.NewRequest("GET", "/?sleep=1s", nil), Transform{})
+
+		assert.Equal(t, http.StatusGatewayTimeout, rec.Code)
+		assert.Equal(t, proxy.ErrResponseHeaderTimeout, err)
+
+		rec = httptest.NewRecorder()
+		x.Forward(rec, httptest.NewRequest("POST", "/?sleep=10ms", strings.NewReader("hello")), Transform{})
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "hello", rec.Body.String())
+	})
+
+	t.Run("Deadline header", func(t *testing.T) {
+		n := proxy.Limits{Timeout: time.Second, DeadlineHeader: "X-Request-Timeout"}
+
+		rec := forward(n, httptest.NewRequest("GET", "/", nil))
+		d, _ := strconv.Atoi(rec.Header().Get("X-Deadline"))
+		assert.True(t, d > 900 && d <= 1000, d)
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Request-Timeout", "200")
+
+		rec = forward(n, req)
+		d, _ = strconv.Atoi(rec.Header().Get("X-Deadline"))
+		assert.True(t, d > 100 && d <= 200, d)
+
+		req = httptest.NewRequest("GET", "/?sleep=1s", nil)
+		req.Header.Set("X-Request-Timeout", "50")
+
+		rec = forward(n, req)
+		assert.Equal(t, http.StatusGatewayTimeout, rec.Code)
+	})
+}
@@ -0,0 +1,248 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"time"
+
+	httpclone "github.com/bukalapak/ottoman/http/clone"
+)
+
+const (
+	defaultMirrorTimeout  = 10 * time.Second
+	defaultMirrorBodySize = 1 << 20
+)
+
+// MirrorRecorder receives the comparison between primary and shadow responses.
+type MirrorRecorder interface {
+	Record(res *MirrorResult)
+}
+
+// MirrorResponse is the summary of a primary or shadow response.
+type MirrorResponse struct {
+	StatusCode int
+	Latency    time.Duration
+	Body       []byte
+}
+
+// MirrorResult is the comparison between primary and shadow responses of a mirrored request.
+type MirrorResult struct {
+	Request *http.Request
+	Primary MirrorResponse
+	Shadow  MirrorResponse
+
+	// Err is the error of either primary or shadow request, if any.
+	Err error
+}
+
+// StatusMatch reports whether both responses have the same status code.
+func (m *MirrorResult) StatusMatch() bool {
+	return m.Primary.StatusCode == m.Shadow.StatusCode
+}
+
+// BodyMatch reports whether both responses have the same body. Only available when Mirror.CompareBody is set.
+func (m *MirrorResult) BodyMatch() bool {
+	return bytes.Equal(m.Primary.Body, m.Shadow.Body)
+}
+
+// Mirror is a Transformer which asynchronously duplicates a sampled percentage of requests to a shadow Targeter.
+// Shadow responses are discarded and never affect the primary response.
+type Mirror struct {
+	Transformer
+
+	Shadow Targeter
+
+	// Percentage of requests mirrored, from 0 to 100. Upgrade requests, e.g. WebSocket, are never mirrored.
+	Percentage float64
+
+	// Transport sends the shadow requests. Default to http.DefaultTransport.
+	Transport http.RoundTripper
+
+	// Timeout of a shadow request. Default to 10s.
+	Timeout time.Duration
+
+	// MaxBodySize is the maximum request body size mirrored, also the maximum response body size compared.
+	// Requests with larger or unknown length body are not mirrored. Default to 1MB.
+	MaxBodySize int64
+
+	// Recorder optionally receives status, latency and body comparison of mirrored requests.
+	Recorder MirrorRecorder
+
+	// CompareBody captures both response bodies for the Recorder.
+	CompareBody bool
+}
+
+func NewMirror(t Transformer, shadow Targeter, percentage float64) *Mirror {
+	return &Mirror{Transformer: t, Shadow: shadow, Percentage: percentage}
+}
+
+func (m *Mirror) RoundTrip(r *http.Request) (*http.Response, error) {
+	req, u, ok := m.shadowRequest(r)
+	if !ok {
+		return m.Transformer.RoundTrip(r)
+	}
+
+	res := &MirrorResult{Request: req}
+	done := make(chan struct{})
+
+	go m.mirror(res, u, done)
+
+	now := time.Now()
+
+	resp, err := m.Transformer.RoundTrip(r)
+	if err != nil {
+		res.Err = err
+		close(done)
+
+		return resp, err
+	}
+
+	res.Primary.StatusCode = resp.StatusCode
+	res.Primary.Latency = time.Since(now)
+
+	if m.Recorder != nil && m.CompareBody {
+		resp.Body = &captureBody{ReadCloser: resp.Body, limit: m.maxBodySize(), done: done, res: &res.Primary}
+	} else {
+		close(done)
+	}
+
+	return resp, err
+}
+
+func (m *Mirror) shadowRequest(r *http.Request) (*http.Request, *url.URL, bool) {
+	if m.Percentage <= 0 || isUpgrade(r) || rand.Float64()*100 >= m.Percentage {
+		return nil, nil, false
+	}
+
+	var body []byte
+
+	if r.Body != nil && r.Body != http.NoBody {
+		if r.ContentLength <= 0 || r.ContentLength > m.maxBodySize() {
+			return nil, nil, false
+		}
+
+		b, rc, err := httpclone.DumpBody(r.Body)
+		r.Body = rc
+
+		if err != nil {
+			return nil, nil, false
+		}
+
+		body = b
+	}
+
+	u := m.Shadow.Target()
+	if u == nil {
+		return nil, nil, false
+	}
+
+	req := httpclone.Request(r)
+	req.URL.Scheme = u.Scheme
+	req.URL.Host = u.Host
+	req.Host = u.Host
+	req.RequestURI = ""
+	req.Body = nil
+
+	if body != nil {
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	return req, u, true
+}
+
+func (m *Mirror) mirror(res *MirrorResult, u *url.URL, done <-chan struct{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout())
+	defer cancel()
+
+
+	now := time.Now()
+
+	resp, err := m.transport().RoundTrip(res.Request.WithContext(ctx))
+	if err == nil {
+		res.Shadow.StatusCode = resp.StatusCode
+		res.Shadow.Latency = time.Since(now)
+
+		if m.Recorder != nil && m.CompareBody {
+			res.Shadow.Body, _ = io.ReadAll(io.LimitReader(resp.Body, m.maxBodySize()))
+		}
+
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	if x, ok := m.Shadow.(Releaser); ok {
+		x.Release(u)
+	}
+
+	if m.Recorder == nil {
+		return
+	}
+
+	<-done
+
+	if res.Err == nil {
+		res.Err = err
+	}
+
+	m.Recorder.Record(res)
+}
+
+func (m *Mirror) transport() http.RoundTripper {
+	if m.Transport == nil {
+		return http.DefaultTransport
+	}
+
+	return m.Transport
+}
+
+func (m *Mirror) timeout() time.Duration {
+	if m.Timeout <= 0 {
+		return defaultMirrorTimeout
+	}
+
+	return m.Timeout
+}
+
+func (m *Mirror) maxBodySize() int64 {
+	if m.MaxBodySize <= 0 {
+		return defaultMirrorBodySize
+	}
+
+	return m.MaxBodySize
+}
+
+// captureBody captures the primary response body while it's streamed to the client.
+type captureBody struct {
+	io.ReadCloser
+	limit  int64
+	buf    bytes.Buffer
+	done   chan struct{}
+	res    *MirrorResponse
+	closed bool
+}
+
+func (b *captureBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+
+	if k := b.limit - int64(b.buf.Len()); k > 0 {
+		b.buf.Write(p[:min(int64(n), k)])
+	}
+
+	return n, err
+}
+
+func (b *captureBody) Close() error {
+	err := b.ReadCloser.Close()
+
+	if !b.closed {
+		b.closed = true
+		b.res.Body = b.buf.Bytes()
+		close(b.done)
+	}
+
+	return err
+}
@@ -0,0 +1,170 @@
+package proxy_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bukalapak/ottoman/proxy"
+	"github.com/stretchr/testify/assert"
+)
+
+type recorder chan *proxy.MirrorResult
+
+func (r recorder) Record(res *proxy.MirrorResult) {
+	r <- res
+}
+
+func TestMirror(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		io.WriteString(w, "primary "+string(b))
+	}))
+	defer primary.Close()
+
+	var hits int32
+
+	bodies := make(chan string, 10)
+
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+
+		b, _ := io.ReadAll(r.Body)
+		bodies <- string(b)
+
+		w.WriteHeader(http.StatusAccepted)
+		io.WriteString(w, "shadow "+string(b))
+	}))
+	defer shadow.Close()
+
+	up, _ := url.Parse(primary.URL)
+	us, _ := url.Parse(shadow.URL)
+
+	forward := func(m *proxy.Mirror, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+
+		proxy.NewProxy(proxy.NewRingTarget([]*url.URL{up})).Forward(rec, req, m)
+
+		return rec
+	}
+
+	t.Run("Mirrored", func(t *testing.T) {
+		m := proxy.NewMirror(Transform{}, proxy.NewRingTarget([]*url.URL{us}), 100)
+		rec := forward(m, "hello")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "primary hello", rec.Body.String())
+		assert.Equal(t, "1", rec.Header().Get("X-Modified"))
+
+		select {
+		case b := <-bodies:
+			assert.Equal(t, "hello", b)
+		case <-time.After(time.Second):
+			t.Fatal("shadow request is not sent")
+		}
+	})
+
+	t.Run("Not sampled", func(t *testing.T) {
+		n := atomic.LoadInt32(&hits)
+
+		m := proxy.NewMirror(Transform{}, proxy.NewRingTarget([]*url.URL{us}), 0)
+		rec := forward(m, "hello")
+
+		assert.Equal(t, "primary hello", rec.Body.String())
+
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(t, n, atomic.LoadInt32(&hits))
+	})
+
+	t.Run("Body too large", func(t *testing.T) {
+		n := atomic.LoadInt32(&hits)
+
+		m := proxy.NewMirror(Transform{}, proxy.NewRingTarget([]*url.URL{us}), 100)
+		m.MaxBodySize = 3
+
+		rec := forward(m, "hello")
+
+		assert.Equal(t, "primary hello", rec.Body.String())
+
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(t, n, atomic.LoadInt32(&hits))
+	})
+
+	t.Run("Recorder", func(t *testing.T) {
+		ch := make(recorder, 1)
+
+		m := proxy.NewMirror(Transform{}, proxy.NewRingTarget([]*url.URL{us}), 100)
+		m.Recorder = ch
+		m.CompareBody = true
+
+		rec := forward(m, "hi")
+		assert.Equal(t, "primary hi", rec.Body.String())
+
+		<-bodies
+
+		select {
+		case res := <-ch:
+			assert.Nil(t, res.Err)
+			assert.Equal(t, http.StatusOK, res.Primary.StatusCode)
+			assert.Equal(t, http.StatusAccepted, res.Shadow.StatusCode)
+			assert.Equal(t, "primary hi", string(res.Primary.Body))
+			assert.Equal(t, "shadow hi", string(res.Shadow.Body))
+			assert.False(t, res.StatusMatch())
+			assert.False(t, res.BodyMatch())
+			assert.True(t, res.Primary.Latency > 0)
+		case <-time.After(time.Second):
+			t.Fatal("mirror result is not recorded")
+		}
+	})
+
+	t.Run("Shadow failure", func(t *testing.T) {
+		ch := make(recorder, 1)
+		dead, _ := url.Parse("http://127.0.0.1:1")
+
+		m := proxy.NewMirror(Transform{}, proxy.NewRingTarget([]*url.URL{dead}), 100)
+		m.Recorder = ch
+
+		rec := forward(m, "hi")
+		assert.Equal(t, "primary hi", rec.Body.String())
+
+		res := <-ch
+		assert.NotNil(t, res.Err)
+		assert.Equal(t, http.StatusOK, res.Primary.StatusCode)
+	})
+
+	t.Run("Release", func(t *testing.T) {
+		x := &countingTarget{LeastTarget: proxy.NewLeastTarget([]*url.URL{us})}
+		m := proxy.NewMirror(Transform{}, x, 100)
+
+		for i := 0; i < 5; i++ {
+			forward(m, "hi")
+			<-bodies
+		}
+
+		assert.Eventually(t, func() bool { return atomic.LoadInt32(&x.released) == 5 }, time.Second, 5*time.Millisecond)
+		assert.Equal(t, int32(5), atomic.LoadInt32(&x.targeted))
+	})
+}
+
+// countingTarget counts the targets chosen and released.
+type countingTarget struct {
+	*proxy.LeastTarget
+	targeted int32
+	released int32
+}
+
+func (x *countingTarget) Target() *url.URL {
+	atomic.AddInt32(&x.targeted, 1)
+	return x.LeastTarget.Target()
+}
+
+func (x *countingTarget) Release(u *url.URL) {
+	atomic.AddInt32(&x.released, 1)
+	x.LeastTarget.Release(u)
+}
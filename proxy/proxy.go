@@ -1,6 +1,7 @@
 package proxy
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"net/http/httputil"
@@ -14,6 +15,10 @@ type Transformer interface {
 	ModifyResponse(*http.Response) error
 }
 
+// ErrNoTarget is returned when the Targeter has no target, e.g. RingTarget without member.
+// Proxy responds it with 503 Service Unavailable.
+var ErrNoTarget = errors.New("proxy: no target available")
+
 type Forwarder interface {
 	Forward(w http.ResponseWriter, r *http.Request, c Transformer)
 }
@@ -23,10 +28,20 @@ type Proxy struct {
 	FlushInterval time.Duration
 	Logger        *log.Logger
 	ErrorHandler  func(http.ResponseWriter, *http.Request, error)
+	Retry         RetryPolicy
+	Limits        Limits
+
+	// Hook receives the report of every forwarded request.
+	Hook Hook
+
+	// IdleTimeout closes upgraded connections, e.g. WebSocket, after being idle for the duration. Zero means no timeout.
+	IdleTimeout time.Duration
+
+	conns *connTracker
 }
 
 func NewProxy(target Targeter) *Proxy {
-	return &Proxy{target: target}
+	return &Proxy{target: target, conns: newConnTracker()}
 }
 
 func (p *Proxy) Target() *url.URL {
@@ -34,14 +49,112 @@ func (p *Proxy) Target() *url.URL {
 }
 
 func (p *Proxy) Forward(w http.ResponseWriter, r *http.Request, n Transformer) {
+	upgrade := isUpgrade(r)
+	if upgrade && p.conns.isDraining() {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+
+	t := &boundTarget{target: p.target, req: r}
+	defer t.release()
+
+	director := n.Director(t)
+
 	proxy := &httputil.ReverseProxy{
-		Director:       n.Director(p.target),
-		Transport:      n,
+		Director: func(r *http.Request) {
+			if t.prepare() {
+				director(r)
+			}
+		},
+		Transport:      &targetCheck{RoundTripper: n, target: t},
 		ModifyResponse: n.ModifyResponse,
 		FlushInterval:  p.FlushInterval,
 		ErrorLog:       p.Logger,
-		ErrorHandler:   p.ErrorHandler,
+		ErrorHandler:   p.errorHandler(),
+	}
+
+	if h, ok := p.target.(HealthReporter); ok {
+		p.reportHealth(proxy, h, t)
+	}
+
+	if p.Hook != nil {
+		rw, req, o := p.observe(proxy, w, r)
+		defer o.done(rw, p.Hook)
+
+		w, r = rw, req
+	}
+
+	if !upgrade && p.Limits.enabled() {
+		req, cancel, ok := p.limit(proxy, w, r)
+		if !ok {
+			return
+		}
+
+		defer cancel()
+
+		r = req
+	}
+
+	if p.Retry.MaxAttempt > 1 {
+		p.retry(proxy)
+	}
+
+	if upgrade {
+		p.upgrade(proxy)
 	}
 
 	proxy.ServeHTTP(w, r)
 }
+
+// reportHealth reports the response of every attempt. Failed attempts are reported by the retrier, except the last
+// one reported here using the target of the last attempt, as the request given to ErrorHandler is the first one.
+func (p *Proxy) reportHealth(proxy *httputil.ReverseProxy, h HealthReporter, t *boundTarget) {
+	modifyResponse := proxy.ModifyResponse
+	proxy.ModifyResponse = func(resp *http.Response) error {
+		h.MarkSuccess(resp.Request.URL)
+		return modifyResponse(resp)
+	}
+
+	errorHandler := p.errorHandler()
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		if t.url != nil {
+			h.MarkFailure(t.url)
+		}
+
+		errorHandler(w, r, err)
+	}
+}
+
+func (p *Proxy) errorHandler() func(http.ResponseWriter, *http.Request, error) {
+	if p.ErrorHandler != nil {
+		return p.ErrorHandler
+	}
+
+	return func(w http.ResponseWriter, r *http.Request, err error) {
+		if p.Logger != nil {
+			p.Logger.Printf("http: proxy error: %v", err)
+		} else {
+			log.Printf("http: proxy error: %v", err)
+		}
+
+		w.WriteHeader(errorStatus(err))
+	}
+}
+
+// targetCheck fails the requests of attempts without target, before reaching the Transformer.
+type targetCheck struct {
+	http.RoundTripper
+	target *boundTarget
+}
+
+func (x *targetCheck) RoundTrip(r *http.Request) (*http.Response, error) {
+	if x.target.url == nil {
+		if r.Body != nil {
+			r.Body.Close()
+		}
+
+		return nil, ErrNoTarget
+	}
+
+	return x.RoundTripper.RoundTrip(r)
+}
@@ -0,0 +1,181 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"strconv"
+
+	httpclone "github.com/bukalapak/ottoman/http/clone"
+)
+
+const defaultRetryBodySize = 1 << 20
+
+var idempotentMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodOptions,
+	http.MethodTrace,
+	http.MethodPut,
+	http.MethodDelete,
+}
+
+// RetryPolicy is the configuration for retrying failed upstream requests on a different target.
+// Requests are retried on connection errors or configured status codes.
+type RetryPolicy struct {
+	// MaxAttempt is the maximum number of attempts, including the first one. Zero or one disables retry.
+	MaxAttempt int
+
+	// StatusCodes are the upstream response status codes to retry on, e.g. 502 and 503.
+	StatusCodes []int
+
+	// Methods are the retryable request methods. Default to idempotent methods. Upgrade requests are never retried.
+	Methods []string
+
+	// MaxBodySize is the maximum request body size buffered for replay. Default to 1MB.
+	// Requests with larger or unknown length body are not retried.
+	MaxBodySize int64
+
+	// Header is the response header recording the number of attempts made, e.g. "X-Proxy-Attempts".
+	Header string
+}
+
+func (n RetryPolicy) enabled(r *http.Request) bool {
+	if n.MaxAttempt <= 1 {
+		return false
+	}
+
+	ms := n.Methods
+	if len(ms) == 0 {
+		ms = idempotentMethods
+	}
+
+	for _, m := range ms {
+		if m == r.Method {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (n RetryPolicy) maxBodySize() int64 {
+	if n.MaxBodySize <= 0 {
+		return defaultRetryBodySize
+	}
+
+	return n.MaxBodySize
+}
+
+func (n RetryPolicy) retryStatus(code int) bool {
+	for _, c := range n.StatusCodes {
+		if c == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+// retrier retries upstream requests of a single Forward call, choosing a new target on every attempt.
+type retrier struct {
+	http.RoundTripper
+	policy   RetryPolicy
+	director func(*http.Request)
+	reporter HealthReporter
+	original *http.Request
+	attempts int
+}
+
+func (p *Proxy) retry(proxy *httputil.ReverseProxy) {
+	x := &retrier{
+		RoundTripper: proxy.Transport,
+		policy:       p.Retry,
+		director:     proxy.Director,
+	}
+
+	if h, ok := p.target.(HealthReporter); ok {
+		x.reporter = h
+	}
+
+	proxy.Director = func(r *http.Request) {
+		x.original = httpclone.Request(r)
+		x.director(r)
+	}
+
+	proxy.Transport = x
+
+	if p.Retry.Header == "" {
+		return
+	}
+
+	modifyResponse := proxy.ModifyResponse
+	proxy.ModifyResponse = func(resp *http.Response) error {
+		resp.Header.Set(p.Retry.Header, strconv.Itoa(x.attempts))
+		return modifyResponse(resp)
+	}
+
+	errorHandler := p.errorHandler()
+	if proxy.ErrorHandler != nil {
+		errorHandler = proxy.ErrorHandler
+	}
+
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		w.Header().Set(p.Retry.Header, strconv.Itoa(x.attempts))
+		errorHandler(w, r, err)
+	}
+}
+
+func (x *retrier) RoundTrip(r *http.Request) (*http.Response, error) {
+	body, ok := x.replayBody(r)
+
+	for {
+		x.attempts++
+
+		resp, err := x.RoundTripper.RoundTrip(r)
+		if !ok || x.attempts >= x.policy.MaxAttempt || r.Context().Err() != nil {
+			return resp, err
+		}
+
+		if err == nil && !x.policy.retryStatus(resp.StatusCode) {
+			return resp, nil
+		}
+
+		if x.reporter != nil {
+			x.reporter.MarkFailure(r.URL)
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		r = httpclone.Request(x.original).WithContext(r.Context())
+		x.director(r)
+
+		if body != nil {
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+	}
+}
+
+// replayBody buffers the request body, reporting whether the request can be retried.
+func (x *retrier) replayBody(r *http.Request) ([]byte, bool) {
+	if !x.policy.enabled(r) || isUpgrade(r) {
+		return nil, false
+	}
+
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, true
+	}
+
+	if r.ContentLength <= 0 || r.ContentLength > x.policy.maxBodySize() {
+		return nil, false
+	}
+
+	b, body, err := httpclone.DumpBody(r.Body)
+	r.Body = body
+
+	return b, err == nil
+}
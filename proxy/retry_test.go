@@ -0,0 +1,172 @@
+package proxy_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bukalapak/ottoman/proxy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxy_Retry(t *testing.T) {
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		io.WriteString(w, r.Method+" "+string(b))
+	}))
+	defer good.Close()
+
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer bad.Close()
+
+	parse := func(ss ...string) []*url.URL {
+		us := make([]*url.URL, len(ss))
+		for i := range ss {
+			us[i], _ = url.Parse(ss[i])
+		}
+
+		return us
+	}
+
+	dead := "http://127.0.0.1:1"
+
+	policy := proxy.RetryPolicy{
+		MaxAttempt:  3,
+		StatusCodes: []int{http.StatusServiceUnavailable},
+		Header:      "X-Proxy-Attempts",
+	}
+
+	forward := func(us []*url.URL, policy proxy.RetryPolicy, req *http.Request) *httptest.ResponseRecorder {
+		x := proxy.NewProxy(proxy.NewRingTarget(us))
+		x.Retry = policy
+
+		rec := httptest.NewRecorder()
+		x.Forward(rec, req, Transform{})
+
+		return rec
+	}
+
+	t.Run("Connection error", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/", nil)
+		rec := forward(parse(dead, good.URL), policy, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "GET ", rec.Body.String())
+		assert.Equal(t, "2", rec.Header().Get("X-Proxy-Attempts"))
+		assert.Equal(t, "1", rec.Header().Get("X-Modified"))
+	})
+
+	t.Run("Status code", func(t *testing.T) {
+		req, _ := http.NewRequest("PUT", "/", strings.NewReader("hello"))
+		rec := forward(parse(bad.URL, good.URL), policy, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "PUT hello", rec.Body.String())
+		assert.Equal(t, "2", rec.Header().Get("X-Proxy-Attempts"))
+	})
+
+	t.Run("Non idempotent method", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", "/", strings.NewReader("hello"))
+		rec := forward(parse(bad.URL, good.URL), policy, req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Equal(t, "1", rec.Header().Get("X-Proxy-Attempts"))
+	})
+
+	t.Run("Configured method", func(t *testing.T) {
+		p := policy
+		p.Methods = []string{"POST"}
+
+		req, _ := http.NewRequest("POST", "/", strings.NewReader("hello"))
+		rec := forward(parse(bad.URL, good.URL), p, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "POST hello", rec.Body.String())
+	})
+
+	t.Run("Body too large", func(t *testing.T) {
+		p := policy
+		p.MaxBodySize = 2
+
+		req, _ := http.NewRequest("PUT", "/", strings.NewReader("hello"))
+		rec := forward(parse(bad.URL, good.URL), p, req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Equal(t, "1", rec.Header().Get("X-Proxy-Attempts"))
+	})
+
+	t.Run("Exhausted", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/", nil)
+		rec := forward(parse(dead, "http://127.0.0.1:2", "http://127.0.0.1:3", good.URL), policy, req)
+
+		assert.Equal(t, http.StatusBadGateway, rec.Code)
+		assert.Equal(t, "3", rec.Header().Get("X-Proxy-Attempts"))
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/", nil)
+		rec := forward(parse(dead, good.URL), proxy.RetryPolicy{}, req)
+
+		assert.Equal(t, http.StatusBadGateway, rec.Code)
+		assert.Empty(t, rec.Header().Get("X-Proxy-Attempts"))
+	})
+
+	t.Run("Request targeter", func(t *testing.T) {
+		var mu sync.Mutex
+
+		hits := make(map[string]int)
+		failing := ""
+
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			if failing == "" {
+				failing = r.Host
+			}
+
+			hits[r.Host]++
+
+			if r.Host == failing {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		})
+
+		s1 := httptest.NewServer(h)
+		defer s1.Close()
+
+		s2 := httptest.NewServer(h)
+		defer s2.Close()
+
+		us := parse(s1.URL, s2.URL)
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Key", "foo")
+
+		for _, target := range []proxy.Targeter{
+			proxy.NewHashTarget(us, proxy.HeaderKey("X-Key")),
+			proxy.NewLeastTarget(us),
+			proxy.NewP2CTarget(us),
+		} {
+			hits = make(map[string]int)
+			failing = ""
+
+			x := proxy.NewProxy(target)
+			x.Retry = policy
+
+			rec := httptest.NewRecorder()
+			x.Forward(rec, req, Transform{})
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "2", rec.Header().Get("X-Proxy-Attempts"))
+			assert.Equal(t, 1, hits[failing])
+			assert.Len(t, hits, 2)
+		}
+	})
+}
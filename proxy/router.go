@@ -0,0 +1,306 @@
+package proxy
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	httpclone "github.com/bukalapak/ottoman/http/clone"
+	"github.com/pkg/errors"
+)
+
+// Route maps matching requests to a Targeter and Transformer pair.
+// Empty matchers match any request.
+type Route struct {
+	// Host matches request host, ignoring port. A leading "*." matches any subdomain, e.g. "*.example.com".
+	Host string
+
+	// Prefix matches request path on segment boundary, e.g. "/api" matches "/api" and "/api/users", not "/apis".
+	Prefix string
+
+	Methods []string
+
+	// Headers matches request header values. An empty value matches any request having the header.
+	Headers map[string]string
+
+	// StripPrefix removes the matched Prefix from request path.
+	StripPrefix bool
+
+	// Rewrite replaces the matched Prefix of request path, e.g. "/v2".
+	Rewrite string
+
+	Target      Targeter
+	Transformer Transformer
+
+	// Limits of the default Forwarder.
+	Limits Limits
+
+	// Forwarder forwards the matching requests. Default to a Proxy of Target.
+	Forwarder Forwarder
+}
+
+// Router is a http.Handler dispatching requests to the first matching route.
+// Routes can be replaced at any time, in-flight requests keep using the routes they're matched against.
+type Router struct {
+	routes atomic.Value
+
+	// NotFound handles requests without matching route. Default to http.NotFound.
+	NotFound http.Handler
+}
+
+func NewRouter(routes []*Route) *Router {
+	t := &Router{}
+	t.Update(routes)
+
+	return t
+}
+
+// Update atomically replaces the routing table.
+func (t *Router) Update(routes []*Route) {
+	rs := make([]*Route, len(routes))
+
+	for i, r := range routes {
+		x := *r
+		if x.Forwarder == nil {
+			p := NewProxy(x.Target)
+			p.Limits = x.Limits
+			x.Forwarder = p
+		}
+
+		rs[i] = &x
+	}
+
+	t.routes.Store(rs)
+}
+
+// Reload replaces the routing table from JSON config, see LoadRoutes.
+func (t *Router) Reload(r io.Reader, transformers map[string]Transformer) error {
+	rs, err := LoadRoutes(r, transformers)
+	if err != nil {
+		return err
+	}
+
+	t.Update(rs)
+
+	return nil
+}
+
+// Routes returns the current routing table.
+func (t *Router) Routes() []*Route {
+	rs, _ := t.routes.Load().([]*Route)
+	return rs
+}
+
+// Match returns the first route matching the request, or nil.
+func (t *Router) Match(r *http.Request) *Route {
+	for _, x := range t.Routes() {
+		if x.match(r) {
+			return x
+		}
+	}
+
+	return nil
+}
+
+func (t *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	x := t.Match(r)
+	if x == nil {
+		if t.NotFound != nil {
+			t.NotFound.ServeHTTP(w, r)
+		} else {
+			http.NotFound(w, r)
+		}
+
+		return
+	}
+
+	x.Forwarder.Forward(w, x.rewrite(r), x.Transformer)
+}
+
+func (x *Route) match(r *http.Request) bool {
+	return x.matchHost(r.Host) && x.matchPath(r.URL.Path) && x.matchMethod(r.Method) && x.matchHeader(r.Header)
+}
+
+func (x *Route) matchHost(host string) bool {
+	if x.Host == "" {
+		return true
+	}
+
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	host = strings.ToLower(host)
+	pattern := strings.ToLower(x.Host)
+
+	if strings.HasPrefix(pattern, "*.") {
+		return strings.HasSuffix(host, pattern[1:])
+	}
+
+	return host == pattern
+}
+
+func (x *Route) matchPath(path string) bool {
+	p := strings.TrimSuffix(x.Prefix, "/")
+
+	return p == "" || path == p || strings.HasPrefix(path, p+"/")
+}
+
+func (x *Route) matchMethod(method string) bool {
+	if len(x.Methods) == 0 {
+		return true
+	}
+
+	for _, m := range x.Methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (x *Route) matchHeader(h http.Header) bool {
+	for k, v := range x.Headers {
+		vs := h.Values(k)
+		if len(vs) == 0 {
+			return false
+		}
+
+		if v != "" && vs[0] != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (x *Route) rewrite(r *http.Request) *http.Request {
+	if !x.StripPrefix && x.Rewrite == "" {
+		return r
+	}
+
+	path := x.Rewrite + strings.TrimPrefix(r.URL.Path, strings.TrimSuffix(x.Prefix, "/"))
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	req := r.WithContext(r.Context())
+	req.URL = httpclone.URL(r.URL)
+	req.URL.Path = path
+	req.URL.RawPath = ""
+
+	return req
+}
+
+// RouteConfig is the JSON representation of a Route.
+type RouteConfig struct {
+	Host        string            `json:"host"`
+	Prefix      string            `json:"prefix"`
+	Methods     []string          `json:"methods"`
+	Headers     map[string]string `json:"headers"`
+	StripPrefix bool              `json:"strip_prefix"`
+	Rewrite     string            `json:"rewrite"`
+
+	// Targets are the upstream URLs, balanced using RingTarget.
+	Targets []string `json:"targets"`
+
+	// Transformer is the name of a registered Transformer.
+	Transformer string `json:"transformer"`
+
+	// Timeout and ResponseHeaderTimeout are durations parsed by time.ParseDuration, e.g. "5s".
+	Timeout               string `json:"timeout"`
+	ResponseHeaderTimeout string `json:"response_header_timeout"`
+	MaxBodySize           int64  `json:"max_body_size"`
+	DeadlineHeader        string `json:"deadline_header"`
+}
+
+// LoadRoutes decodes JSON array of RouteConfig into routes, looking up transformers by name.
+func LoadRoutes(r io.Reader, transformers map[string]Transformer) ([]*Route, error) {
+	var cs []RouteConfig
+
+	if err := json.NewDecoder(r).Decode(&cs); err != nil {
+		return nil, errors.Wrap(err, "invalid route config")
+	}
+
+	rs := make([]*Route, len(cs))
+
+	for i, c := range cs {
+		x, err := c.route(transformers)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid route #%d", i)
+		}
+
+		rs[i] = x
+	}
+
+	return rs, nil
+}
+
+func (c RouteConfig) route(transformers map[string]Transformer) (*Route, error) {
+	if len(c.Targets) == 0 {
+		return nil, errors.New("no target")
+	}
+
+	us := make([]*url.URL, len(c.Targets))
+
+	for i, s := range c.Targets {
+		u, err := url.Parse(s)
+		if err != nil {
+			return nil, err
+		}
+
+		if u.Scheme == "" || u.Host == "" {
+			return nil, errors.Errorf("invalid target: %s", s)
+		}
+
+		us[i] = u
+	}
+
+	n, ok := transformers[c.Transformer]
+	if !ok {
+		return nil, errors.Errorf("unknown transformer: %q", c.Transformer)
+	}
+
+	limits := Limits{MaxBodySize: c.MaxBodySize, DeadlineHeader: c.DeadlineHeader}
+
+	if err := parseDuration(c.Timeout, &limits.Timeout); err != nil {
+		return nil, err
+	}
+
+	if err := parseDuration(c.ResponseHeaderTimeout, &limits.ResponseHeaderTimeout); err != nil {
+		return nil, err
+	}
+
+	return &Route{
+		Host:        c.Host,
+		Prefix:      c.Prefix,
+		Methods:     c.Methods,
+		Headers:     c.Headers,
+		StripPrefix: c.StripPrefix,
+		Rewrite:     c.Rewrite,
+		Target:      NewRingTarget(us),
+		Transformer: n,
+		Limits:      limits,
+	}, nil
+}
+
+func parseDuration(s string, d *time.Duration) error {
+	if s == "" {
+		return nil
+	}
+
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = v
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package proxy_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/bukalapak/ottoman/proxy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouter(t *testing.T) {
+	backend := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, name+" "+r.URL.Path)
+		}))
+	}
+
+	b1 := backend("b1")
+	defer b1.Close()
+
+	b2 := backend("b2")
+	defer b2.Close()
+
+	target := func(s string) proxy.Targeter {
+		u, _ := url.Parse(s)
+		return proxy.NewTarget(u)
+	}
+
+	x := proxy.NewRouter([]*proxy.Route{
+		{Host: "*.example.com", Prefix: "/api", StripPrefix: true, Target: target(b2.URL), Transformer: Transform{}},
+		{Prefix: "/v1/", Rewrite: "/v2", Methods: []string{"GET"}, Target: target(b2.URL), Transformer: Transform{}},
+		{Prefix: "/admin", Headers: map[string]string{"X-Admin": ""}, Target: target(b2.URL), Transformer: Transform{}},
+		{Prefix: "/", Target: target(b1.URL), Transformer: Transform{}},
+	})
+
+	serve := func(method, host, path string, h ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Host = host
+
+		for i := 0; i+1 < len(h); i += 2 {
+			req.Header.Set(h[i], h[i+1])
+		}
+
+		rec := httptest.NewRecorder()
+		x.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	data := []struct {
+		method string
+		host   string
+		path   string
+		header []string
+		body   string
+	}{
+		{"GET", "api.example.com:8080", "/api/users", nil, "b2 /users"},
+		{"GET", "api.example.com", "/api", nil, "b2 /"},
+		{"GET", "example.com", "/api/users", nil, "b1 /api/users"},
+		{"GET", "api.example.com", "/apis", nil, "b1 /apis"},
+		{"GET", "localhost", "/v1/users", nil, "b2 /v2/users"},
+		{"POST", "localhost", "/v1/users", nil, "b1 /v1/users"},
+		{"GET", "localhost", "/admin", []string{"X-Admin", "1"}, "b2 /admin"},
+		{"GET", "localhost", "/admin", nil, "b1 /admin"},
+	}
+
+	for _, v := range data {
+		rec := serve(v.method, v.host, v.path, v.header...)
+		assert.Equal(t, v.body, rec.Body.String(), v.method+" "+v.host+v.path)
+	}
+
+	x.Update([]*proxy.Route{{Prefix: "/only", Target: target(b1.URL), Transformer: Transform{}}})
+
+	rec := serve("GET", "localhost", "/users")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	x.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	rec = serve("GET", "localhost", "/users")
+	assert.Equal(t, http.StatusTeapot, rec.Code)
+}
+
+func TestRouter_Reload(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.URL.Path)
+	}))
+	defer backend.Close()
+
+	ts := map[string]proxy.Transformer{"default": Transform{}}
+	x := proxy.NewRouter(nil)
+
+	config := `[{"prefix":"/api","strip_prefix":true,"methods":["GET"],"targets":["` + backend.URL + `"],"transformer":"default","max_body_size":5}]`
+	assert.Nil(t, x.Reload(strings.NewReader(config), ts))
+	assert.Len(t, x.Routes(), 1)
+
+	rec := httptest.NewRecorder()
+	x.ServeHTTP(rec, httptest.NewRequest("GET", "/api/users", nil))
+
+	assert.Equal(t, "/users", rec.Body.String())
+	assert.Equal(t, "1", rec.Header().Get("X-Modified"))
+	assert.Equal(t, int64(5), x.Routes()[0].Limits.MaxBodySize)
+
+	data := []string{
+		`{`,
+		`[{"prefix":"/api","targets":[],"transformer":"default"}]`,
+		`[{"prefix":"/api","targets":["/foo"],"transformer":"default"}]`,
+		`[{"prefix":"/api","targets":["` + backend.URL + `"],"transformer":"unknown"}]`,
+		`[{"prefix":"/api","targets":["` + backend.URL + `"],"transformer":"default","timeout":"1x"}]`,
+	}
+
+	for _, s := range data {
+		assert.NotNil(t, x.Reload(strings.NewReader(s), ts), s)
+		assert.Len(t, x.Routes(), 1)
+	}
+}
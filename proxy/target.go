@@ -1,8 +1,8 @@
 package proxy
 
 import (
-	"container/ring"
 	"net/url"
+	"sync"
 )
 
 type Targeter interface {
@@ -21,28 +21,80 @@ func (t *Target) Target() *url.URL {
 	return t.baseURL
 }
 
+// RingTarget is a round robin Targeter, safe for concurrent use.
+// Members are removed after failed health probes or consecutive proxy errors, see StartHealthCheck.
 type RingTarget struct {
-	ringURL *ring.Ring
+	mu      sync.Mutex
+	members []*member
+	next    int
+	health  *healthChecker
 }
 
 func NewRingTarget(baseURL []*url.URL) *RingTarget {
-	return &RingTarget{ringURL: urlRing(baseURL)}
+	ms := make([]*member, len(baseURL))
+
+	for i := range baseURL {
+		ms[i] = &member{url: baseURL[i], healthy: true}
+	}
+
+	return &RingTarget{members: ms}
 }
 
+// Target returns the next healthy member. When no member is healthy, all members are used.
+// It returns nil when there is no member, e.g. before the first discovery, see Watch.
 func (t *RingTarget) Target() *url.URL {
-	u := t.ringURL.Value.(*url.URL)
-	t.ringURL = t.ringURL.Next()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	n := len(t.members)
+	if n == 0 {
+		return nil
+	}
+
+	for i := 0; i < n; i++ {
+		m := t.members[(t.next+i)%n]
+
+		if m.healthy {
+			t.next = (t.next + i + 1) % n
+			return m.url
+		}
+	}
+
+	u := t.members[t.next%n].url
+	t.next = (t.next + 1) % n
 
 	return u
 }
 
-func urlRing(us []*url.URL) *ring.Ring {
-	r := ring.New(len(us))
+// Members returns the current healthy members.
+func (t *RingTarget) Members() []*url.URL {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	us := make([]*url.URL, 0, len(t.members))
+
+	for _, m := range t.members {
+		if m.healthy {
+			us = append(us, m.url)
+		}
+	}
+
+	return us
+}
+
+type member struct {
+	url     *url.URL
+	healthy bool
+	fails   int
+	passes  int
+}
 
-	for i := 0; i < r.Len(); i++ {
-		r.Value = us[i]
-		r = r.Next()
+func (t *RingTarget) lookup(u *url.URL) *member {
+	for _, m := range t.members {
+		if m.url.Scheme == u.Scheme && m.url.Host == u.Host {
+			return m
+		}
 	}
 
-	return r
+	return nil
 }
@@ -2,7 +2,10 @@ package proxy_test
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"sync"
 	"testing"
 
 	"github.com/bukalapak/ottoman/proxy"
@@ -39,3 +42,54 @@ func TestRingTarget(t *testing.T) {
 		assert.Equal(t, fmt.Sprintf("s%d.example.com", i), z.Host)
 	}
 }
+
+func TestRingTarget_empty(t *testing.T) {
+	x := proxy.NewRingTarget(nil)
+	assert.Nil(t, x.Target())
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	rec := httptest.NewRecorder()
+
+	p := proxy.NewProxy(x)
+	p.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		assert.Equal(t, proxy.ErrNoTarget, err)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	p.Forward(rec, req, Transform{})
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
+
+func TestRingTarget_Concurrent(t *testing.T) {
+	us := make([]*url.URL, 3)
+
+	for i := range us {
+		us[i], _ = url.Parse(fmt.Sprintf("http://s%d.example.com/", i+1))
+	}
+
+	x := proxy.NewRingTarget(us)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	hits := make(map[string]int)
+
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			z := x.Target()
+
+			mu.Lock()
+			hits[z.Host]++
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, map[string]int{"s1.example.com": 10, "s2.example.com": 10, "s3.example.com": 10}, hits)
+	assert.Len(t, x.Members(), 3)
+}
@@ -0,0 +1,262 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Modifier is a composable part of Transformer, see Chain. Nil functions are skipped.
+type Modifier struct {
+	// Request modifies the outgoing request, after it's directed to the target.
+	Request func(r *http.Request)
+
+	// Response modifies the upstream response.
+	Response func(resp *http.Response) error
+}
+
+type transformer struct {
+	http.RoundTripper
+}
+
+// NewTransformer returns the standard Transformer. It directs requests to the target scheme and host, prefixing
+// the target path and merging the target query, then sends them using transport. Nil transport means
+// http.DefaultTransport.
+func NewTransformer(transport http.RoundTripper) Transformer {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	return transformer{RoundTripper: transport}
+}
+
+func (n transformer) Director(t Targeter) func(*http.Request) {
+	return func(r *http.Request) {
+		u := t.Target()
+
+		r.URL.Scheme = u.Scheme
+		r.URL.Host = u.Host
+		r.URL.Path = joinPath(u.Path, r.URL.Path)
+		r.URL.RawPath = ""
+
+		switch {
+		case u.RawQuery == "":
+		case r.URL.RawQuery == "":
+			r.URL.RawQuery = u.RawQuery
+		default:
+			r.URL.RawQuery = u.RawQuery + "&" + r.URL.RawQuery
+		}
+	}
+}
+
+func (n transformer) ModifyResponse(resp *http.Response) error {
+	return nil
+}
+
+func joinPath(a, b string) string {
+	if a == "" {
+		return b
+	}
+
+	if b == "" {
+		return a
+	}
+
+	return strings.TrimSuffix(a, "/") + "/" + strings.TrimPrefix(b, "/")
+}
+
+type chain struct {
+	Transformer
+	ms []Modifier
+}
+
+// Chain merges base Transformer and modifiers into one Transformer. Requests are directed by base, then modified
+// by each Modifier in order, and sent using base. Responses are modified by base, then by each Modifier in order.
+func Chain(base Transformer, ms ...Modifier) Transformer {
+	if c, ok := base.(*chain); ok {
+		return &chain{Transformer: c.Transformer, ms: append(append([]Modifier(nil), c.ms...), ms...)}
+	}
+
+	return &chain{Transformer: base, ms: ms}
+}
+
+func (c *chain) Director(t Targeter) func(*http.Request) {
+	director := c.Transformer.Director(t)
+
+	return func(r *http.Request) {
+		director(r)
+
+		for _, m := range c.ms {
+			if m.Request != nil {
+				m.Request(r)
+			}
+		}
+	}
+}
+
+func (c *chain) ModifyResponse(resp *http.Response) error {
+	if err := c.Transformer.ModifyResponse(resp); err != nil {
+		return err
+	}
+
+	for _, m := range c.ms {
+		if m.Response == nil {
+			continue
+		}
+
+		if err := m.Response(resp); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SetRequestHeader sets request header k to v.
+func SetRequestHeader(k, v string) Modifier {
+	return Modifier{Request: func(r *http.Request) { r.Header.Set(k, v) }}
+}
+
+// AddRequestHeader appends v to request header k.
+func AddRequestHeader(k, v string) Modifier {
+	return Modifier{Request: func(r *http.Request) { r.Header.Add(k, v) }}
+}
+
+// DelRequestHeader removes request headers ks.
+func DelRequestHeader(ks ...string) Modifier {
+	return Modifier{Request: func(r *http.Request) { delHeader(r.Header, ks) }}
+}
+
+// RenameRequestHeader moves values of request header from to header to.
+func RenameRequestHeader(from, to string) Modifier {
+	return Modifier{Request: func(r *http.Request) { renameHeader(r.Header, from, to) }}
+}
+
+// SetResponseHeader sets response header k to v.
+func SetResponseHeader(k, v string) Modifier {
+	return Modifier{Response: func(resp *http.Response) error {
+		resp.Header.Set(k, v)
+		return nil
+	}}
+}
+
+// AddResponseHeader appends v to response header k.
+func AddResponseHeader(k, v string) Modifier {
+	return Modifier{Response: func(resp *http.Response) error {
+		resp.Header.Add(k, v)
+		return nil
+	}}
+}
+
+// DelResponseHeader removes response headers ks.
+func DelResponseHeader(ks ...string) Modifier {
+	return Modifier{Response: func(resp *http.Response) error {
+		delHeader(resp.Header, ks)
+		return nil
+	}}
+}
+
+// RenameResponseHeader moves values of response header from to header to.
+func RenameResponseHeader(from, to string) Modifier {
+	return Modifier{Response: func(resp *http.Response) error {
+		renameHeader(resp.Header, from, to)
+		return nil
+	}}
+}
+
+// SetHost overrides the Host header sent upstream. Empty host means the target host.
+func SetHost(host string) Modifier {
+	return Modifier{Request: func(r *http.Request) {
+		if host == "" {
+			r.Host = r.URL.Host
+		} else {
+			r.Host = host
+		}
+	}}
+}
+
+// SetQuery sets query parameter k to v.
+func SetQuery(k, v string) Modifier {
+	return RewriteQuery(func(q url.Values) { q.Set(k, v) })
+}
+
+// DelQuery removes query parameters ks.
+func DelQuery(ks ...string) Modifier {
+	return RewriteQuery(func(q url.Values) {
+		for _, k := range ks {
+			q.Del(k)
+		}
+	})
+}
+
+// RewriteQuery modifies the query parameters using fn.
+func RewriteQuery(fn func(q url.Values)) Modifier {
+	return Modifier{Request: func(r *http.Request) {
+		q := r.URL.Query()
+		fn(q)
+		r.URL.RawQuery = q.Encode()
+	}}
+}
+
+// Forwarded sets X-Forwarded-Host and X-Forwarded-Proto headers, and appends RFC 7239 Forwarded header,
+// describing the incoming request. It should precede SetHost. X-Forwarded-For is maintained by httputil.ReverseProxy.
+func Forwarded() Modifier {
+	return Modifier{Request: func(r *http.Request) {
+		proto := "http"
+		if r.TLS != nil {
+			proto = "https"
+		}
+
+		r.Header.Set("X-Forwarded-Host", r.Host)
+		r.Header.Set("X-Forwarded-Proto", proto)
+
+		v := "proto=" + proto
+
+		if r.Host != "" {
+			v = "host=" + quoteForwarded(r.Host) + ";" + v
+		}
+
+		if ip, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			v = "for=" + forwardedNode(ip) + ";" + v
+		}
+
+		if s := r.Header.Get("Forwarded"); s != "" {
+			v = s + ", " + v
+		}
+
+		r.Header.Set("Forwarded", v)
+	}}
+}
+
+func forwardedNode(ip string) string {
+	if strings.Contains(ip, ":") {
+		return `"[` + ip + `]"`
+	}
+
+	return ip
+}
+
+func quoteForwarded(s string) string {
+	if strings.ContainsAny(s, ":[]") {
+		return `"` + s + `"`
+	}
+
+	return s
+}
+
+func delHeader(h http.Header, ks []string) {
+	for _, k := range ks {
+		h.Del(k)
+	}
+}
+
+func renameHeader(h http.Header, from, to string) {
+	vs := h.Values(from)
+	if len(vs) == 0 {
+		return
+	}
+
+	h.Del(from)
+	h[http.CanonicalHeaderKey(to)] = vs
+}
@@ -0,0 +1,96 @@
+package proxy_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/bukalapak/ottoman/proxy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChain(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Internal", "secret")
+		w.Header().Set("X-Upstream", "backend")
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"host":    r.Host,
+			"path":    r.URL.Path,
+			"query":   r.URL.RawQuery,
+			"header":  r.Header,
+			"forward": r.Header.Get("Forwarded"),
+		})
+	}))
+	defer backend.Close()
+
+	u, _ := url.Parse(backend.URL + "/base?key=abc")
+
+	n := proxy.Chain(proxy.NewTransformer(nil),
+		proxy.Forwarded(),
+		proxy.SetHost("api.internal"),
+		proxy.SetRequestHeader("X-Set", "1"),
+		proxy.AddRequestHeader("X-Add", "2"),
+		proxy.DelRequestHeader("X-Remove"),
+		proxy.RenameRequestHeader("X-Old", "X-New"),
+		proxy.SetQuery("page", "2"),
+		proxy.DelQuery("debug"),
+	)
+
+	n = proxy.Chain(n,
+		proxy.DelResponseHeader("X-Internal"),
+		proxy.RenameResponseHeader("X-Upstream", "X-Origin"),
+		proxy.SetResponseHeader("X-Set", "1"),
+		proxy.AddResponseHeader("X-Add", "2"),
+	)
+
+	req := httptest.NewRequest("GET", "http://example.com/users?debug=1&page=1", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Add", "1")
+	req.Header.Set("X-Remove", "1")
+	req.Header.Set("X-Old", "old")
+
+	rec := httptest.NewRecorder()
+	proxy.NewProxy(proxy.NewTarget(u)).Forward(rec, req, n)
+
+	var v struct {
+		Host    string      `json:"host"`
+		Path    string      `json:"path"`
+		Query   string      `json:"query"`
+		Header  http.Header `json:"header"`
+		Forward string      `json:"forward"`
+	}
+
+	assert.Nil(t, json.NewDecoder(rec.Body).Decode(&v))
+
+	assert.Equal(t, "api.internal", v.Host)
+	assert.Equal(t, "/base/users", v.Path)
+	assert.Equal(t, "key=abc&page=2", v.Query)
+	assert.Equal(t, "1", v.Header.Get("X-Set"))
+	assert.Equal(t, []string{"1", "2"}, v.Header.Values("X-Add"))
+	assert.Empty(t, v.Header.Get("X-Remove"))
+	assert.Empty(t, v.Header.Get("X-Old"))
+	assert.Equal(t, "old", v.Header.Get("X-New"))
+	assert.Equal(t, "example.com", v.Header.Get("X-Forwarded-Host"))
+	assert.Equal(t, "http", v.Header.Get("X-Forwarded-Proto"))
+	assert.Equal(t, "10.0.0.1", v.Header.Get("X-Forwarded-For"))
+	assert.Equal(t, "for=10.0.0.1;host=example.com;proto=http", v.Forward)
+
+	assert.Empty(t, rec.Header().Get("X-Internal"))
+	assert.Empty(t, rec.Header().Get("X-Upstream"))
+	assert.Equal(t, "backend", rec.Header().Get("X-Origin"))
+	assert.Equal(t, "1", rec.Header().Get("X-Set"))
+	assert.Equal(t, "2", rec.Header().Get("X-Add"))
+}
+
+func TestForwarded(t *testing.T) {
+	req := httptest.NewRequest("GET", "http://[::1]:8080/", nil)
+	req.RemoteAddr = "[2001:db8::1]:1234"
+	req.Header.Set("Forwarded", "for=192.0.2.43")
+
+	proxy.Forwarded().Request(req)
+
+	assert.Equal(t, `for=192.0.2.43, for="[2001:db8::1]";host="[::1]:8080";proto=http`, req.Header.Get("Forwarded"))
+}
@@ -0,0 +1,203 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+	"sync"
+	"time"
+)
+
+const drainPollInterval = 50 * time.Millisecond
+
+// upgrader tracks the connections upgraded by upstream, e.g. WebSocket.
+type upgrader struct {
+	http.RoundTripper
+	conns   *connTracker
+	timeout time.Duration
+}
+
+func (p *Proxy) upgrade(proxy *httputil.ReverseProxy) {
+	proxy.Transport = &upgrader{
+		RoundTripper: proxy.Transport,
+		conns:        p.conns,
+		timeout:      p.IdleTimeout,
+	}
+}
+
+func (x *upgrader) RoundTrip(r *http.Request) (*http.Response, error) {
+	resp, err := x.RoundTripper.RoundTrip(r)
+	if err != nil || resp.StatusCode != http.StatusSwitchingProtocols {
+		return resp, err
+	}
+
+	rwc, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		return resp, err
+	}
+
+	resp.Body = x.conns.track(rwc, r.URL.Host, x.timeout)
+
+	return resp, nil
+}
+
+// Connections returns the number of active upgraded connections per target host.
+func (p *Proxy) Connections() map[string]int {
+	return p.conns.count()
+}
+
+// Shutdown gracefully drains upgraded connections. New upgrade requests are rejected with 503 status code, while
+// active connections are waited until closed. When ctx is done before, remaining connections are closed and
+// the context error is returned. Regular requests are drained by http.Server.Shutdown.
+func (p *Proxy) Shutdown(ctx context.Context) error {
+	return p.conns.drain(ctx)
+}
+
+func isUpgrade(r *http.Request) bool {
+	if r.Header.Get("Upgrade") == "" {
+		return false
+	}
+
+	for _, v := range r.Header.Values("Connection") {
+		for _, s := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(s), "upgrade") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+type connTracker struct {
+	mu       sync.Mutex
+	conns    map[*upgradedConn]struct{}
+	draining bool
+}
+
+func newConnTracker() *connTracker {
+	return &connTracker{conns: make(map[*upgradedConn]struct{})}
+}
+
+func (t *connTracker) track(rwc io.ReadWriteCloser, host string, timeout time.Duration) *upgradedConn {
+	c := &upgradedConn{ReadWriteCloser: rwc, tracker: t, host: host, timeout: timeout}
+
+	if timeout > 0 {
+		c.timer = time.AfterFunc(timeout, func() { c.Close() })
+	}
+
+	t.mu.Lock()
+	t.conns[c] = struct{}{}
+	t.mu.Unlock()
+
+	return c
+}
+
+func (t *connTracker) untrack(c *upgradedConn) {
+	t.mu.Lock()
+	delete(t.conns, c)
+	t.mu.Unlock()
+}
+
+func (t *connTracker) isDraining() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.draining
+}
+
+func (t *connTracker) count() map[string]int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	m := make(map[string]int)
+
+	for c := range t.conns {
+		m[c.host]++
+	}
+
+	return m
+}
+
+func (t *connTracker) drain(ctx context.Context) error {
+	t.mu.Lock()
+	t.draining = true
+	t.mu.Unlock()
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if len(t.count()) == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			t.closeAll()
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (t *connTracker) closeAll() {
+	t.mu.Lock()
+	cs := make([]*upgradedConn, 0, len(t.conns))
+
+	for c := range t.conns {
+		cs = append(cs, c)
+	}
+
+	t.mu.Unlock()
+
+	for _, c := range cs {
+		c.Close()
+	}
+}
+
+// upgradedConn is the upstream side of an upgraded connection, closed after being idle for timeout.
+type upgradedConn struct {
+	io.ReadWriteCloser
+	tracker *connTracker
+	host    string
+	timeout time.Duration
+	timer   *time.Timer
+	once    sync.Once
+}
+
+func (c *upgradedConn) Read(b []byte) (int, error) {
+	n, err := c.ReadWriteCloser.Read(b)
+	c.touch()
+
+	return n, err
+}
+
+func (c *upgradedConn) Write(b []byte) (int, error) {
+	n, err := c.ReadWriteCloser.Write(b)
+	c.touch()
+
+	return n, err
+}
+
+func (c *upgradedConn) Close() error {
+	err := c.ReadWriteCloser.Close()
+
+	c.once.Do(func() {
+		if c.timer != nil {
+			c.timer.Stop()
+		}
+
+		c.tracker.untrack(c)
+	})
+
+	return err
+}
+
+func (c *upgradedConn) touch() {
+	if c.timer != nil {
+		c.timer.Reset(c.timeout)
+	}
+}
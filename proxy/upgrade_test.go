@@ -0,0 +1,239 @@
+package proxy_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/bukalapak/ottoman/proxy"
+	"github.com/stretchr/testify/assert"
+)
+
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+func wsAccept(key string) string {
+	h := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// wsEcho is a minimal WebSocket server echoing text frames.
+func wsEcho() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "websocket" {
+			http.Error(w, "upgrade required", http.StatusUpgradeRequired)
+			return
+		}
+
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+
+		defer conn.Close()
+
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+		rw.WriteString("Sec-WebSocket-Accept: " + wsAccept(r.Header.Get("Sec-WebSocket-Key")) + "\r\n\r\n")
+		rw.Flush()
+
+		for {
+			msg, err := wsRead(rw.Reader)
+			if err != nil {
+				return
+			}
+
+			if err := wsWrite(conn, msg, false); err != nil {
+				return
+			}
+		}
+	}))
+}
+
+func wsWrite(w io.Writer, msg string, masked bool) error {
+	b := []byte{0x81, byte(len(msg))}
+	p := []byte(msg)
+
+	if masked {
+		mask := []byte{1, 2, 3, 4}
+		b[1] |= 0x80
+		b = append(b, mask...)
+
+		for i := range p {
+			p[i] ^= mask[i%4]
+		}
+	}
+
+	_, err := w.Write(append(b, p...))
+
+	return err
+}
+
+func wsRead(r *bufio.Reader) (string, error) {
+	h := make([]byte, 2)
+	if _, err := io.ReadFull(r, h); err != nil {
+		return "", err
+	}
+
+	n := int(h[1] & 0x7f)
+	if n == 126 {
+		var x uint16
+		if err := binary.Read(r, binary.BigEndian, &x); err != nil {
+			return "", err
+		}
+
+		n = int(x)
+	}
+
+	var mask []byte
+
+	if h[1]&0x80 != 0 {
+		mask = make([]byte, 4)
+		if _, err := io.ReadFull(r, mask); err != nil {
+			return "", err
+		}
+	}
+
+	p := make([]byte, n)
+	if _, err := io.ReadFull(r, p); err != nil {
+		return "", err
+	}
+
+	for i := range p {
+		if mask != nil {
+			p[i] ^= mask[i%4]
+		}
+	}
+
+	return string(p), nil
+}
+
+func wsDial(t *testing.T, addr string) (net.Conn, *bufio.Reader, *http.Response) {
+	conn, err := net.Dial("tcp", addr)
+	assert.Nil(t, err)
+
+	req, _ := http.NewRequest("GET", "http://"+addr+"/ws", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Write(conn)
+
+	br := bufio.NewReader(conn)
+
+	resp, err := http.ReadResponse(br, req)
+	assert.Nil(t, err)
+
+	return conn, br, resp
+}
+
+func TestProxy_Upgrade(t *testing.T) {
+	backend := wsEcho()
+	defer backend.Close()
+
+	u, _ := url.Parse(backend.URL)
+
+	newServer := func(x *proxy.Proxy, n proxy.Transformer) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			x.Forward(w, r, n)
+		}))
+	}
+
+	t.Run("Echo", func(t *testing.T) {
+		x := proxy.NewProxy(proxy.NewRingTarget([]*url.URL{u}))
+		x.Retry = proxy.RetryPolicy{MaxAttempt: 2, StatusCodes: []int{http.StatusBadGateway}}
+
+		m := proxy.NewMirror(Transform{}, proxy.NewTarget(u), 100)
+		m.Recorder = make(recorder, 1)
+		m.CompareBody = true
+
+		s := newServer(x, m)
+		defer s.Close()
+
+		conn, br, resp := wsDial(t, s.Listener.Addr().String())
+		defer conn.Close()
+
+		assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+		assert.Equal(t, wsAccept("dGhlIHNhbXBsZSBub25jZQ=="), resp.Header.Get("Sec-WebSocket-Accept"))
+		assert.Equal(t, map[string]int{u.Host: 1}, x.Connections())
+
+		for _, msg := range []string{"hello", "world"} {
+			assert.Nil(t, wsWrite(conn, msg, true))
+
+			b, err := wsRead(br)
+			assert.Nil(t, err)
+			assert.Equal(t, msg, b)
+		}
+
+		conn.Close()
+
+		assert.Eventually(t, func() bool { return len(x.Connections()) == 0 }, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Idle timeout", func(t *testing.T) {
+		x := proxy.NewProxy(proxy.NewTarget(u))
+		x.IdleTimeout = 100 * time.Millisecond
+
+		s := newServer(x, proxy.NewTransformer(nil))
+		defer s.Close()
+
+		conn, br, _ := wsDial(t, s.Listener.Addr().String())
+		defer conn.Close()
+
+		assert.Nil(t, wsWrite(conn, "ping", true))
+
+		b, err := wsRead(br)
+		assert.Nil(t, err)
+		assert.Equal(t, "ping", b)
+
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+
+		_, err = wsRead(br)
+		assert.Equal(t, io.EOF, err)
+		assert.Eventually(t, func() bool { return len(x.Connections()) == 0 }, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Shutdown", func(t *testing.T) {
+		x := proxy.NewProxy(proxy.NewTarget(u))
+		assert.Nil(t, x.Shutdown(context.Background()))
+
+		x = proxy.NewProxy(proxy.NewTarget(u))
+
+		s := newServer(x, proxy.NewTransformer(nil))
+		defer s.Close()
+
+		conn, br, _ := wsDial(t, s.Listener.Addr().String())
+		defer conn.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		done := make(chan struct{})
+
+		go func() {
+			defer close(done)
+			time.Sleep(20 * time.Millisecond)
+
+			c, _, resp := wsDial(t, s.Listener.Addr().String())
+			defer c.Close()
+
+			assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		}()
+
+		assert.Equal(t, context.DeadlineExceeded, x.Shutdown(ctx))
+		<-done
+
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+
+		_, err := wsRead(br)
+		assert.Equal(t, io.EOF, err)
+		assert.Empty(t, x.Connections())
+	})
+}
@@ -3,6 +3,7 @@ package redis
 
 import (
 	"errors"
+	"sync"
 	"time"
 
 	redisc "github.com/go-redis/redis/v7"
@@ -10,6 +11,8 @@ import (
 
 var errCacheMiss = errors.New("redis: cache miss")
 
+const scanCount = 100
+
 // Option represents configurable configuration for redis client.
 type Option struct {
 	Addrs    []string
@@ -36,6 +39,8 @@ type connector interface {
 	Incr(key string) *redisc.IntCmd
 	Expire(key string, expiration time.Duration) *redisc.BoolCmd
 	Del(keys ...string) *redisc.IntCmd
+	Scan(cursor uint64, match string, count int64) *redisc.ScanCmd
+	TTL(key string) *redisc.DurationCmd
 }
 
 // Redis is a Redis client representing a pool of zero or more underlying connections.
@@ -156,3 +161,63 @@ func (c *Redis) Delete(key string) error {
 
 	return nil
 }
+
+// Scan returns all keys matching given pattern.
+// On Redis Cluster, every master node is scanned and the keys are merged.
+func (c *Redis) Scan(pattern string) ([]string, error) {
+	cc, ok := c.client.(*redisc.ClusterClient)
+	if !ok {
+		return scan(c.client, pattern)
+	}
+
+	var mu sync.Mutex
+	var keys []string
+
+	err := cc.ForEachMaster(func(client *redisc.Client) error {
+		ks, err := scan(client, pattern)
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		keys = append(keys, ks...)
+		mu.Unlock()
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func scan(client connector, pattern string) ([]string, error) {
+	var keys []string
+
+	iter := client.Scan(0, pattern, scanCount).Iterator()
+	for iter.Next() {
+		keys = append(keys, iter.Val())
+	}
+
+	return keys, iter.Err()
+}
+
+// TTL returns the remaining time to live of given key.
+// Zero duration is returned for key without expiration.
+func (c *Redis) TTL(key string) (time.Duration, error) {
+	d, err := c.client.TTL(key).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	switch d {
+	case -2:
+		return 0, errCacheMiss
+	case -1:
+		return 0, nil
+	}
+
+	return d, nil
+}
@@ -2,6 +2,7 @@ package redis_test
 
 import (
 	"os"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -36,6 +37,9 @@ func TestRedis(t *testing.T) {
 		t.Run("Expire", func(t *testing.T) { testExpire(t, client, c) })
 		t.Run("Delete", func(t *testing.T) { testDelete(t, client, c) })
 		t.Run("Delete-Unknown", func(t *testing.T) { testDeleteUnknown(t, c) })
+		t.Run("Scan", func(t *testing.T) { testScan(t, client, c) })
+		t.Run("Scan-All", func(t *testing.T) { testScanAll(t, client, c) })
+		t.Run("TTL", func(t *testing.T) { testTTL(t, client, c) })
 	})
 
 	t.Run("RedisCluster", func(t *testing.T) {
@@ -51,6 +55,8 @@ func TestRedis(t *testing.T) {
 		t.Run("Expire", func(t *testing.T) { testExpire(t, client, c) })
 		t.Run("Delete", func(t *testing.T) { testDelete(t, client, c) })
 		t.Run("Delete-Unknown", func(t *testing.T) { testDeleteUnknown(t, c) })
+		t.Run("Scan", func(t *testing.T) { testScan(t, client, c) })
+		t.Run("Scan-All", func(t *testing.T) { testScanAll(t, client, c) })
 		t.Run("ReadMulti-CROSSSLOT", func(t *testing.T) {
 			loadFixtures(client)
 
@@ -154,6 +160,52 @@ func testExpire(t *testing.T, client Connector, c *redis.Redis) {
 	cleanFixtures(client)
 }
 
+func testScan(t *testing.T, client Connector, c *redis.Redis) {
+	loadFixtures(client)
+
+	keys, err := c.Scan("fo*")
+	assert.Nil(t, err)
+	assert.Contains(t, keys, "foo")
+	assert.NotContains(t, keys, "baz")
+
+	cleanFixtures(client)
+}
+
+func testScanAll(t *testing.T, client Connector, c *redis.Redis) {
+	for i := 0; i < 50; i++ {
+		client.Set("scan:"+strconv.Itoa(i), "x", time.Minute)
+	}
+
+	keys, err := c.Scan("scan:*")
+	assert.Nil(t, err)
+	assert.Len(t, keys, 50)
+
+	for i := 0; i < 50; i++ {
+		client.Del("scan:" + strconv.Itoa(i))
+	}
+}
+
+func testTTL(t *testing.T, client Connector, c *redis.Redis) {
+	err := client.Set("foo", "bar", time.Minute).Err()
+	assert.Nil(t, err)
+
+	d, err := c.TTL("foo")
+	assert.Nil(t, err)
+	assert.Equal(t, time.Minute, d)
+
+	err = client.Set("foo", "bar", 0).Err()
+	assert.Nil(t, err)
+
+	d, err = c.TTL("foo")
+	assert.Nil(t, err)
+	assert.Zero(t, d)
+
+	_, err = c.TTL("unknown")
+	assert.NotNil(t, err)
+
+	cleanFixtures(client)
+}
+
 func testDelete(t *testing.T, client Connector, c *redis.Redis) {
 	loadFixtures(client)
 